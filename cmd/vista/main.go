@@ -5,13 +5,24 @@ import (
 	"github.com/rybkr/gitvista/internal/gitcore"
 	"github.com/rybkr/gitvista/internal/server"
 	"log"
+	"os"
+	"strings"
 )
 
 func main() {
 	repoPath := flag.String("repo", ".", "Path to git repository")
+	noReplaceObjects := flag.Bool("no-replace-objects", os.Getenv("GIT_NO_REPLACE_OBJECTS") != "", "Ignore replace refs")
+	includeRefs := flag.String("include-refs", "", "Comma-separated ref patterns to load (default: all of refs/)")
+	excludeRefs := flag.String("exclude-refs", "", "Comma-separated ref patterns to skip")
 	flag.Parse()
 
-	repo, err := gitcore.NewRepository(*repoPath)
+	repo, err := gitcore.NewRepositoryWithOptions(*repoPath, gitcore.Options{
+		NoReplaceObjects: *noReplaceObjects,
+		Refs: gitcore.RefFilter{
+			Include: splitPatterns(*includeRefs),
+			Exclude: splitPatterns(*excludeRefs),
+		},
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -19,3 +30,14 @@ func main() {
     serv := server.NewServer(repo, "8080")
     serv.Start()
 }
+
+// splitPatterns splits a comma-separated list of ref patterns, ignoring empty items.
+func splitPatterns(list string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(list, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
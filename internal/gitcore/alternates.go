@@ -0,0 +1,101 @@
+package gitcore
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxAlternateDepth bounds how many alternates files are followed in a chain, matching git.
+const maxAlternateDepth = 5
+
+// loadObjectDirs collects the repository's own object directory followed by every object
+// directory it borrows from through objects/info/alternates, as set up by
+// `git clone --reference` or `--shared`.
+// See: https://git-scm.com/docs/gitrepository-layout#Documentation/gitrepository-layout.txt-objectsinfoalternates
+func (r *Repository) loadObjectDirs() {
+	primary := filepath.Join(r.commonDir, "objects")
+	seen := map[string]bool{primary: true}
+	r.objectDirs = []string{primary}
+	r.loadAlternates(primary, seen, 0)
+}
+
+// loadAlternates appends the object directories listed in an object directory's alternates file,
+// recursing into their own alternates. Missing or cyclic entries are skipped.
+func (r *Repository) loadAlternates(objectDir string, seen map[string]bool, depth int) {
+	alternates, err := readAlternates(objectDir)
+	if err != nil {
+		log.Printf("failed to read alternates of %s: %v", objectDir, err)
+		return
+	}
+	if len(alternates) > 0 && depth >= maxAlternateDepth {
+		log.Printf("ignoring alternates of %s: nesting too deep", objectDir)
+		return
+	}
+
+	for _, dir := range alternates {
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			// Log error but continue with other potentially valid alternates.
+			log.Printf("ignoring alternate object directory %s: not a directory", dir)
+			continue
+		}
+		r.objectDirs = append(r.objectDirs, dir)
+		r.loadAlternates(dir, seen, depth+1)
+	}
+}
+
+// readAlternates parses the objects/info/alternates file of an object directory.
+// Relative paths are resolved against the object directory itself. Returns nil if there is no
+// alternates file.
+func readAlternates(objectDir string) ([]string, error) {
+	file, err := os.Open(filepath.Join(objectDir, "info", "alternates"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var dirs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Paths containing unusual characters are written as C-style quoted strings.
+		if strings.HasPrefix(line, `"`) {
+			unquoted, err := strconv.Unquote(line)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted path %s: %w", line, err)
+			}
+			line = unquoted
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(objectDir, line)
+		}
+		dirs = append(dirs, filepath.Clean(line))
+	}
+
+	return dirs, scanner.Err()
+}
+
+// looseObjectPath returns the path of a loose object in the first object directory holding it.
+func (r *Repository) looseObjectPath(id Hash) (string, bool) {
+	for _, dir := range r.objectDirs {
+		objectPath := filepath.Join(dir, string(id)[:2], string(id)[2:])
+		if _, err := os.Stat(objectPath); err == nil {
+			return objectPath, true
+		}
+	}
+	return "", false
+}
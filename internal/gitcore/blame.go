@@ -0,0 +1,247 @@
+package gitcore
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+)
+
+// BlameLine attributes one line of a file to the commit that last changed it.
+// LineNumber is the line's position in the blamed file; OriginalLineNumber and OriginalPath give
+// its position in the commit that introduced it. Line numbers are 1-based.
+type BlameLine struct {
+	Commit             Hash   `json:"commit"`
+	LineNumber         int    `json:"lineNumber"`
+	OriginalLineNumber int    `json:"originalLineNumber"`
+	OriginalPath       string `json:"originalPath"`
+	Text               string `json:"text"`
+}
+
+// blameLineRef tracks a line of the blamed file through history.
+// final is its index in the blamed file; current is its index in the suspect's version.
+type blameLineRef struct {
+	final, current int
+}
+
+// blameSuspect is a version of the file that may be responsible for a set of lines.
+type blameSuspect struct {
+	commit *Commit
+	path   string
+	blob   Hash
+	lines  []blameLineRef
+}
+
+// blameQueue orders suspects from the most recently committed to the oldest, so that a commit
+// collects lines from all of its descendants before it is examined.
+type blameQueue []*blameSuspect
+
+func (q blameQueue) Len() int { return len(q) }
+func (q blameQueue) Less(i, j int) bool {
+	return q[i].commit.Committer.When.After(q[j].commit.Committer.When)
+}
+func (q blameQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *blameQueue) Push(x any)   { *q = append(*q, x.(*blameSuspect)) }
+func (q *blameQueue) Pop() any {
+	old := *q
+	suspect := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return suspect
+}
+
+// Blame attributes every line of the file at path, as of the given commit, to the commit that
+// last changed it. Lines are passed from each commit to the parents they were carried over from,
+// following renames, until a commit introduced them or history runs out.
+func (r *Repository) Blame(commitID Hash, filePath string) ([]BlameLine, error) {
+	commit, err := r.commit(commitID)
+	if err != nil {
+		return nil, err
+	}
+	entry, found, err := r.findTreeEntry(commit.Tree, filePath)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("path not found: %s", filePath)
+	}
+	if entry.Kind != BlobObject {
+		return nil, fmt.Errorf("path %s is not a file", filePath)
+	}
+
+	b := &blamer{
+		repo:     r,
+		contents: make(map[Hash][]byte),
+		pending:  make(map[string]*blameSuspect),
+	}
+	content, err := b.content(entry.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, _ := splitLines(content)
+	b.result = make([]BlameLine, len(lines))
+	root := &blameSuspect{commit: commit, path: filePath, blob: entry.ID}
+	for i, line := range lines {
+		b.result[i] = BlameLine{LineNumber: i + 1, Text: line}
+		root.lines = append(root.lines, blameLineRef{final: i, current: i})
+	}
+	b.enqueue(root)
+
+	for b.queue.Len() > 0 {
+		suspect := heap.Pop(&b.queue).(*blameSuspect)
+		delete(b.pending, suspectKey(suspect.commit.ID, suspect.path))
+		if err := b.examine(suspect); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.result, nil
+}
+
+// blamer holds the state of a single Blame call.
+type blamer struct {
+	repo     *Repository
+	result   []BlameLine
+	queue    blameQueue
+	pending  map[string]*blameSuspect
+	contents map[Hash][]byte
+}
+
+// examine passes as many of a suspect's lines as possible to its parents and attributes the rest
+// to the suspect itself.
+func (b *blamer) examine(suspect *blameSuspect) error {
+	type parentVersion struct {
+		commit *Commit
+		path   string
+		blob   Hash
+	}
+
+	var parents []parentVersion
+	for _, parentID := range suspect.commit.Parents {
+		parent, err := b.repo.commit(parentID)
+		if err != nil {
+			// Missing parents mark the edge of available history; lines stop here.
+			continue
+		}
+		parentPath, parentBlob, found, err := b.repo.blameParentPath(suspect, parent)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+
+		// A parent with identical content takes every line, as git blame does.
+		if parentBlob == suspect.blob {
+			b.enqueue(&blameSuspect{commit: parent, path: parentPath, blob: parentBlob, lines: suspect.lines})
+			return nil
+		}
+		parents = append(parents, parentVersion{commit: parent, path: parentPath, blob: parentBlob})
+	}
+
+	remaining := suspect.lines
+	for _, parent := range parents {
+		if len(remaining) == 0 {
+			break
+		}
+
+		parentContent, err := b.content(parent.blob)
+		if err != nil {
+			return err
+		}
+		content, err := b.content(suspect.blob)
+		if err != nil {
+			return err
+		}
+		mapping := matchLines(parentContent, content)
+
+		var passed, kept []blameLineRef
+		for _, line := range remaining {
+			if old := mapping[line.current]; old != -1 {
+				passed = append(passed, blameLineRef{final: line.final, current: old})
+			} else {
+				kept = append(kept, line)
+			}
+		}
+		if len(passed) > 0 {
+			b.enqueue(&blameSuspect{commit: parent.commit, path: parent.path, blob: parent.blob, lines: passed})
+		}
+		remaining = kept
+	}
+
+	for _, line := range remaining {
+		b.result[line.final].Commit = suspect.commit.ID
+		b.result[line.final].OriginalLineNumber = line.current + 1
+		b.result[line.final].OriginalPath = suspect.path
+	}
+	return nil
+}
+
+// enqueue schedules a suspect, merging its lines into a pending suspect for the same commit and
+// path if there is one.
+func (b *blamer) enqueue(suspect *blameSuspect) {
+	key := suspectKey(suspect.commit.ID, suspect.path)
+	if pending, found := b.pending[key]; found {
+		pending.lines = append(pending.lines, suspect.lines...)
+		return
+	}
+
+	b.pending[key] = suspect
+	heap.Push(&b.queue, suspect)
+}
+
+// content returns the content of a blob, caching it for the duration of the blame.
+func (b *blamer) content(id Hash) ([]byte, error) {
+	if content, found := b.contents[id]; found {
+		return content, nil
+	}
+	content, _, err := b.repo.readBlobContent(id)
+	if err != nil {
+		return nil, err
+	}
+	b.contents[id] = content
+	return content, nil
+}
+
+// suspectKey identifies a version of a file by commit and path.
+func suspectKey(id Hash, filePath string) string {
+	return string(id) + "\x00" + filePath
+}
+
+// blameParentPath locates the suspect's file in a parent commit, following a rename or copy if
+// the path does not exist there. Reports false if the parent has no corresponding file.
+func (r *Repository) blameParentPath(suspect *blameSuspect, parent *Commit) (string, Hash, bool, error) {
+	entry, found, err := r.findTreeEntry(parent.Tree, suspect.path)
+	if err != nil {
+		return "", "", false, err
+	}
+	if found && entry.Kind == BlobObject {
+		return suspect.path, entry.ID, true, nil
+	}
+
+	rename, found, err := r.findRename(parent, suspect.commit, suspect.path)
+	if err != nil || !found {
+		return "", "", false, err
+	}
+	return rename.OldPath, rename.OldHash, true, nil
+}
+
+// findTreeEntry resolves a slash-separated path within a tree.
+// Reports false if the path does not exist.
+func (r *Repository) findTreeEntry(treeID Hash, filePath string) (TreeEntry, bool, error) {
+	entry := TreeEntry{ID: treeID, Mode: ModeTree, Kind: TreeObject}
+	for _, name := range strings.Split(strings.Trim(filePath, "/"), "/") {
+		if entry.Kind != TreeObject {
+			return TreeEntry{}, false, nil
+		}
+		tree, err := r.Tree(entry.ID)
+		if err != nil {
+			return TreeEntry{}, false, err
+		}
+
+		var found bool
+		if entry, found = tree.Entry(name); !found {
+			return TreeEntry{}, false, nil
+		}
+	}
+	return entry, true, nil
+}
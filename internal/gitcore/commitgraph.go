@@ -0,0 +1,295 @@
+package gitcore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Commit-graph chunk identifiers.
+// See: https://git-scm.com/docs/gitformat-commit-graph
+const (
+	chunkOIDFanout          = 0x4f494446 // "OIDF"
+	chunkOIDLookup          = 0x4f49444c // "OIDL"
+	chunkCommitData         = 0x43444154 // "CDAT"
+	chunkGenerationData     = 0x47444132 // "GDA2"
+	chunkGenerationOverflow = 0x47444f32 // "GDO2"
+	chunkExtraEdges         = 0x45444745 // "EDGE"
+)
+
+// Special parent values in the commit data chunk.
+const (
+	graphParentNone    = 0x70000000
+	graphExtraEdgeFlag = 0x80000000
+	graphLastEdgeFlag  = 0x80000000
+	graphOverflowFlag  = 0x80000000
+)
+
+// commitGraph holds a commit-graph file, or a chain of split commit-graph files ordered from the
+// base layer up. Commits are addressed by their global position across all layers.
+type commitGraph struct {
+	layers []*commitGraphLayer
+}
+
+// commitGraphLayer is a single commit-graph file.
+type commitGraphLayer struct {
+	hashSize   int
+	numCommits uint32
+	base       uint32 // Number of commits in all lower layers.
+
+	fanout             []byte
+	oidLookup          []byte
+	commitData         []byte
+	extraEdges         []byte
+	generationData     []byte
+	generationOverflow []byte
+}
+
+// loadCommitGraph reads the repository's commit-graph chain, or its single commit-graph file if
+// there is no chain. Returns nil if neither exists.
+func (r *Repository) loadCommitGraph() (*commitGraph, error) {
+	infoDir := filepath.Join(r.commonDir, "objects", "info")
+
+	chainPath := filepath.Join(infoDir, "commit-graphs", "commit-graph-chain")
+	chain, err := os.Open(chainPath)
+	if err == nil {
+		defer chain.Close()
+
+		graph := &commitGraph{}
+		scanner := bufio.NewScanner(chain)
+		for scanner.Scan() {
+			name := strings.TrimSpace(scanner.Text())
+			if name == "" {
+				continue
+			}
+			layerPath := filepath.Join(infoDir, "commit-graphs", "graph-"+name+".graph")
+			if err := graph.addLayer(layerPath, r.objectFormat); err != nil {
+				return nil, err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return graph, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	graphPath := filepath.Join(infoDir, "commit-graph")
+	if _, err := os.Stat(graphPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+	graph := &commitGraph{}
+	if err := graph.addLayer(graphPath, r.objectFormat); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// addLayer parses a commit-graph file and stacks it on top of the existing layers.
+func (g *commitGraph) addLayer(path string, format ObjectFormat) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(data) < 8 || string(data[:4]) != "CGPH" {
+		return fmt.Errorf("invalid commit-graph signature in %s", path)
+	}
+	if data[4] != 1 {
+		return fmt.Errorf("unsupported commit-graph version %d in %s", data[4], path)
+	}
+
+	hashVersion := map[ObjectFormat]byte{SHA1: 1, SHA256: 2}[format]
+	if data[5] != hashVersion {
+		return fmt.Errorf("commit-graph %s does not use %s", path, format)
+	}
+	if int(data[7]) != len(g.layers) {
+		return fmt.Errorf("commit-graph %s expects %d base graphs, have %d", path, data[7], len(g.layers))
+	}
+
+	chunks, err := readChunkTable(data, 8, int(data[6]))
+	if err != nil {
+		return fmt.Errorf("invalid commit-graph %s: %w", path, err)
+	}
+
+	layer := &commitGraphLayer{
+		hashSize:           format.Size(),
+		fanout:             chunks[chunkOIDFanout],
+		oidLookup:          chunks[chunkOIDLookup],
+		commitData:         chunks[chunkCommitData],
+		extraEdges:         chunks[chunkExtraEdges],
+		generationData:     chunks[chunkGenerationData],
+		generationOverflow: chunks[chunkGenerationOverflow],
+	}
+	if len(layer.fanout) != 256*4 || layer.oidLookup == nil || layer.commitData == nil {
+		return fmt.Errorf("commit-graph %s is missing required chunks", path)
+	}
+	layer.numCommits = binary.BigEndian.Uint32(layer.fanout[255*4:])
+	if len(layer.oidLookup) < int(layer.numCommits)*layer.hashSize ||
+		len(layer.commitData) < int(layer.numCommits)*(layer.hashSize+16) {
+		return fmt.Errorf("commit-graph %s is truncated", path)
+	}
+
+	if len(g.layers) > 0 {
+		top := g.layers[len(g.layers)-1]
+		layer.base = top.base + top.numCommits
+	}
+	g.layers = append(g.layers, layer)
+	return nil
+}
+
+// readChunkTable parses a chunk-format table of contents starting at offset, returning the
+// content of each chunk by identifier.
+// See: https://git-scm.com/docs/gitformat-chunk
+func readChunkTable(data []byte, offset, numChunks int) (map[uint32][]byte, error) {
+	type entry struct {
+		id     uint32
+		offset uint64
+	}
+
+	entries := make([]entry, numChunks+1)
+	for i := range entries {
+		pos := offset + i*12
+		if pos+12 > len(data) {
+			return nil, fmt.Errorf("truncated chunk table")
+		}
+		entries[i] = entry{
+			id:     binary.BigEndian.Uint32(data[pos:]),
+			offset: binary.BigEndian.Uint64(data[pos+4:]),
+		}
+	}
+
+	chunks := make(map[uint32][]byte, numChunks)
+	for i := 0; i < numChunks; i++ {
+		start, end := entries[i].offset, entries[i+1].offset
+		if start > end || end > uint64(len(data)) {
+			return nil, fmt.Errorf("chunk %08x out of bounds", entries[i].id)
+		}
+		chunks[entries[i].id] = data[start:end]
+	}
+	return chunks, nil
+}
+
+// lookup returns the global position of a commit in the graph.
+func (g *commitGraph) lookup(id Hash) (uint32, bool) {
+	raw, err := hex.DecodeString(string(id))
+	if err != nil {
+		return 0, false
+	}
+
+	for _, layer := range g.layers {
+		if len(raw) != layer.hashSize {
+			return 0, false
+		}
+		if pos, found := searchFanout(layer.fanout, layer.oidLookup, layer.hashSize, raw); found {
+			return layer.base + uint32(pos), true
+		}
+	}
+	return 0, false
+}
+
+// layerAt resolves a global position to its layer and the position within that layer.
+func (g *commitGraph) layerAt(pos uint32) (*commitGraphLayer, uint32, error) {
+	for i := len(g.layers) - 1; i >= 0; i-- {
+		if layer := g.layers[i]; pos >= layer.base {
+			if pos-layer.base >= layer.numCommits {
+				break
+			}
+			return layer, pos - layer.base, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("commit-graph position %d out of range", pos)
+}
+
+// hashAt returns the hash of the commit at a global position.
+func (g *commitGraph) hashAt(pos uint32) (Hash, error) {
+	layer, local, err := g.layerAt(pos)
+	if err != nil {
+		return "", err
+	}
+	return NewHashFromBytes(layer.oid(local))
+}
+
+// commit builds a Commit from the graph's record for the commit at a global position.
+// Only the fields stored in the graph are populated; Partial is set on the result.
+func (g *commitGraph) commit(id Hash, pos uint32) (*Commit, error) {
+	layer, local, err := g.layerAt(pos)
+	if err != nil {
+		return nil, err
+	}
+
+	record := layer.commitData[int(local)*(layer.hashSize+16):]
+	tree, err := NewHashFromBytes(record[:layer.hashSize])
+	if err != nil {
+		return nil, err
+	}
+	record = record[layer.hashSize:]
+
+	parent1 := binary.BigEndian.Uint32(record[0:])
+	parent2 := binary.BigEndian.Uint32(record[4:])
+	genAndTimeHigh := binary.BigEndian.Uint32(record[8:])
+	timeLow := binary.BigEndian.Uint32(record[12:])
+	commitTime := int64(genAndTimeHigh&0x3)<<32 | int64(timeLow)
+
+	var parentPositions []uint32
+	if parent1 != graphParentNone {
+		parentPositions = append(parentPositions, parent1)
+	}
+	switch {
+	case parent2 == graphParentNone:
+	case parent2&graphExtraEdgeFlag != 0:
+		for i := int(parent2 &^ graphExtraEdgeFlag); ; i++ {
+			if (i+1)*4 > len(layer.extraEdges) {
+				return nil, fmt.Errorf("commit-graph extra edge %d out of range", i)
+			}
+			edge := binary.BigEndian.Uint32(layer.extraEdges[i*4:])
+			parentPositions = append(parentPositions, edge&^graphLastEdgeFlag)
+			if edge&graphLastEdgeFlag != 0 {
+				break
+			}
+		}
+	default:
+		parentPositions = append(parentPositions, parent2)
+	}
+
+	commit := &Commit{
+		ID:         id,
+		Tree:       tree,
+		Committer:  Signature{When: time.Unix(commitTime, 0)},
+		Generation: uint64(genAndTimeHigh >> 2),
+		Partial:    true,
+	}
+	for _, pos := range parentPositions {
+		parent, err := g.hashAt(pos)
+		if err != nil {
+			return nil, err
+		}
+		commit.Parents = append(commit.Parents, parent)
+	}
+
+	// Prefer the corrected commit date (generation number v2) when the graph records it.
+	if len(layer.generationData) >= int(local+1)*4 {
+		offset := uint64(binary.BigEndian.Uint32(layer.generationData[local*4:]))
+		if offset&graphOverflowFlag != 0 {
+			i := int(offset &^ graphOverflowFlag)
+			if (i+1)*8 > len(layer.generationOverflow) {
+				return nil, fmt.Errorf("commit-graph generation overflow %d out of range", i)
+			}
+			offset = binary.BigEndian.Uint64(layer.generationOverflow[i*8:])
+		}
+		commit.Generation = uint64(commitTime) + offset
+	}
+
+	return commit, nil
+}
+
+// oid returns the raw object name at a position within the layer.
+func (l *commitGraphLayer) oid(local uint32) []byte {
+	start := int(local) * l.hashSize
+	return l.oidLookup[start : start+l.hashSize]
+}
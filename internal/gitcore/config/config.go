@@ -0,0 +1,251 @@
+// Package config reads git configuration files: the system, global and repository files, with
+// git's quoting rules, subsections, multi-valued keys and conditional includes.
+// See: https://git-scm.com/docs/git-config#_configuration_file
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Scope identifies which configuration file an entry came from.
+type Scope int
+
+const (
+	ScopeSystem Scope = iota
+	ScopeGlobal
+	ScopeLocal
+	ScopeWorktree
+)
+
+// String returns the scope's name as used by git config --show-scope.
+func (s Scope) String() string {
+	switch s {
+	case ScopeSystem:
+		return "system"
+	case ScopeGlobal:
+		return "global"
+	case ScopeWorktree:
+		return "worktree"
+	default:
+		return "local"
+	}
+}
+
+// Entry is a single key assignment. Section and Key are lowercased, since they are
+// case-insensitive; Subsection keeps its case. A key given without "=" has NoValue set, which
+// git interprets as boolean true.
+type Entry struct {
+	Section    string
+	Subsection string
+	Key        string
+	Value      string
+	NoValue    bool
+	Scope      Scope
+	File       string
+}
+
+// Config is the ordered list of entries read from one or more files. When a key is assigned
+// more than once, the last assignment wins for single-valued lookups.
+type Config struct {
+	Entries []Entry
+}
+
+// LoadOptions describe the repository a configuration is being read for. They are needed to
+// locate the files and to evaluate includeIf conditions.
+type LoadOptions struct {
+	// GitDir is the repository's .git directory, or a linked worktree's private directory.
+	GitDir string
+	// CommonDir is the directory shared by all worktrees, whose config file is read as the local
+	// scope. It defaults to GitDir.
+	CommonDir string
+	// Branch is the short name of the checked-out branch, for includeIf "onbranch:" conditions.
+	Branch string
+	// NoSystem skips the system config file, like GIT_CONFIG_NOSYSTEM.
+	NoSystem bool
+	// NoGlobal skips the global config files.
+	NoGlobal bool
+}
+
+// Load reads the system, global, repository and worktree configuration, in that order of
+// precedence, following includes. Missing files are skipped.
+func Load(opts LoadOptions) (*Config, error) {
+	c := &Config{}
+	p := parser{config: c, opts: opts}
+
+	if !opts.NoSystem && os.Getenv("GIT_CONFIG_NOSYSTEM") == "" {
+		if err := p.parseFile(systemConfigPath(), ScopeSystem, 0); err != nil {
+			return nil, err
+		}
+	}
+	if !opts.NoGlobal {
+		for _, path := range globalConfigPaths() {
+			if err := p.parseFile(path, ScopeGlobal, 0); err != nil {
+				return nil, err
+			}
+		}
+	}
+	commonDir := opts.CommonDir
+	if commonDir == "" {
+		commonDir = opts.GitDir
+	}
+	if commonDir != "" {
+		if err := p.parseFile(filepath.Join(commonDir, "config"), ScopeLocal, 0); err != nil {
+			return nil, err
+		}
+	}
+	if opts.GitDir != "" && c.Scope(ScopeLocal).Bool("extensions", "", "worktreeconfig", false) {
+		// See: https://git-scm.com/docs/git-worktree#_configuration_file
+		if err := p.parseFile(filepath.Join(opts.GitDir, "config.worktree"), ScopeWorktree, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// LoadFile reads a single configuration file and the files it includes.
+// A missing file yields an empty configuration.
+func LoadFile(path string, scope Scope, opts LoadOptions) (*Config, error) {
+	c := &Config{}
+	p := parser{config: c, opts: opts}
+	if err := p.parseFile(path, scope, 0); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Parse reads configuration held in memory, such as a .gitmodules blob. name identifies the
+// content in errors. Includes are not followed, just as git ignores them in .gitmodules.
+func Parse(data []byte, name string, scope Scope) (*Config, error) {
+	c := &Config{}
+	f := &fileParser{data: data, line: 1, path: name, scope: scope}
+	for {
+		entry, ok, err := f.next()
+		if err != nil {
+			return nil, fmt.Errorf("bad config line %d in %s: %w", f.line, name, err)
+		}
+		if !ok {
+			return c, nil
+		}
+		c.Entries = append(c.Entries, entry)
+	}
+}
+
+// systemConfigPath returns the location of the system-wide configuration file.
+func systemConfigPath() string {
+	if path := os.Getenv("GIT_CONFIG_SYSTEM"); path != "" {
+		return path
+	}
+	return "/etc/gitconfig"
+}
+
+// globalConfigPaths returns the locations of the user's configuration files, lowest precedence
+// first.
+func globalConfigPaths() []string {
+	if path := os.Getenv("GIT_CONFIG_GLOBAL"); path != "" {
+		return []string{path}
+	}
+
+	var paths []string
+	xdgHome := os.Getenv("XDG_CONFIG_HOME")
+	home, _ := os.UserHomeDir()
+	if xdgHome == "" && home != "" {
+		xdgHome = filepath.Join(home, ".config")
+	}
+	if xdgHome != "" {
+		paths = append(paths, filepath.Join(xdgHome, "git", "config"))
+	}
+	if home != "" {
+		paths = append(paths, filepath.Join(home, ".gitconfig"))
+	}
+	return paths
+}
+
+// Get returns the last value assigned to a key, and whether it was set at all.
+func (c *Config) Get(section, subsection, key string) (string, bool) {
+	section, key = strings.ToLower(section), strings.ToLower(key)
+	for i := len(c.Entries) - 1; i >= 0; i-- {
+		entry := c.Entries[i]
+		if entry.Section == section && entry.Subsection == subsection && entry.Key == key {
+			return entry.Value, true
+		}
+	}
+	return "", false
+}
+
+// GetAll returns every value assigned to a multi-valued key, in file order.
+func (c *Config) GetAll(section, subsection, key string) []string {
+	section, key = strings.ToLower(section), strings.ToLower(key)
+	var values []string
+	for _, entry := range c.Entries {
+		if entry.Section == section && entry.Subsection == subsection && entry.Key == key {
+			values = append(values, entry.Value)
+		}
+	}
+	return values
+}
+
+// Bool returns a key interpreted as a git boolean, or def if it is not set or not a boolean.
+// See: https://git-scm.com/docs/git-config#Documentation/git-config.txt-boolean
+func (c *Config) Bool(section, subsection, key string, def bool) bool {
+	section, key = strings.ToLower(section), strings.ToLower(key)
+	for i := len(c.Entries) - 1; i >= 0; i-- {
+		entry := c.Entries[i]
+		if entry.Section != section || entry.Subsection != subsection || entry.Key != key {
+			continue
+		}
+		if entry.NoValue {
+			return true
+		}
+		if value, ok := parseBool(entry.Value); ok {
+			return value
+		}
+		return def
+	}
+	return def
+}
+
+// parseBool interprets git's boolean spellings.
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "on":
+		return true, true
+	case "false", "no", "off", "":
+		return false, true
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return n != 0, true
+	}
+	return false, false
+}
+
+// Subsections returns the distinct subsections of a section, e.g. the names of all remotes for
+// "remote", in order of first appearance.
+func (c *Config) Subsections(section string) []string {
+	section = strings.ToLower(section)
+	seen := make(map[string]bool)
+	var subsections []string
+	for _, entry := range c.Entries {
+		if entry.Section == section && entry.Subsection != "" && !seen[entry.Subsection] {
+			seen[entry.Subsection] = true
+			subsections = append(subsections, entry.Subsection)
+		}
+	}
+	return subsections
+}
+
+// Scope returns the entries that came from a single scope, e.g. settings such as extensions
+// that git only honors in the repository's own config.
+func (c *Config) Scope(scope Scope) *Config {
+	filtered := &Config{}
+	for _, entry := range c.Entries {
+		if entry.Scope == scope {
+			filtered.Entries = append(filtered.Entries, entry)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,397 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxIncludeDepth bounds nested includes, matching git, so that include cycles fail cleanly.
+const maxIncludeDepth = 10
+
+// parser accumulates entries from a configuration file and the files it includes.
+type parser struct {
+	config *Config
+	opts   LoadOptions
+}
+
+// fileParser holds the position within a single file.
+type fileParser struct {
+	data  []byte
+	pos   int
+	line  int
+	path  string
+	scope Scope
+
+	section    string
+	subsection string
+}
+
+// parseFile reads a configuration file, appending its entries and following its includes.
+// A missing file is not an error.
+func (p *parser) parseFile(path string, scope Scope, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("exceeded maximum include depth (%d) while including %s", maxIncludeDepth, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	f := &fileParser{data: data, line: 1, path: path, scope: scope}
+	for {
+		entry, ok, err := f.next()
+		if err != nil {
+			return fmt.Errorf("bad config line %d in file %s: %w", f.line, path, err)
+		}
+		if !ok {
+			return nil
+		}
+		p.config.Entries = append(p.config.Entries, entry)
+
+		if includePath, ok := p.includePath(entry); ok {
+			if err := p.parseFile(includePath, scope, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// includePath returns the file an entry includes, if it is an include.path or a matching
+// includeIf.<condition>.path.
+// See: https://git-scm.com/docs/git-config#_includes
+func (p *parser) includePath(entry Entry) (string, bool) {
+	if entry.Key != "path" || entry.NoValue || entry.Value == "" {
+		return "", false
+	}
+	switch {
+	case entry.Section == "include" && entry.Subsection == "":
+	case entry.Section == "includeif" && p.conditionHolds(entry.Subsection, entry.File):
+	default:
+		return "", false
+	}
+
+	path := expandHome(entry.Value)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(entry.File), path)
+	}
+	return path, true
+}
+
+// conditionHolds evaluates an includeIf condition. Unsupported conditions never hold.
+func (p *parser) conditionHolds(condition, file string) bool {
+	kind, pattern, found := strings.Cut(condition, ":")
+	if !found {
+		return false
+	}
+
+	switch kind {
+	case "gitdir", "gitdir/i":
+		if p.opts.GitDir == "" {
+			return false
+		}
+		pattern = expandHome(pattern)
+		if strings.HasPrefix(pattern, "./") {
+			pattern = filepath.Join(filepath.Dir(file), pattern[2:])
+		} else if !filepath.IsAbs(pattern) && !strings.HasPrefix(pattern, "**/") {
+			pattern = "**/" + pattern
+		}
+		if strings.HasSuffix(pattern, "/") {
+			pattern += "**"
+		}
+		gitDir := filepath.ToSlash(filepath.Clean(p.opts.GitDir))
+		return wildmatch(filepath.ToSlash(pattern), gitDir, kind == "gitdir/i")
+	case "onbranch":
+		if p.opts.Branch == "" {
+			return false
+		}
+		if strings.HasSuffix(pattern, "/") {
+			pattern += "**"
+		}
+		return wildmatch(pattern, p.opts.Branch, false)
+	default:
+		return false
+	}
+}
+
+// expandHome replaces a leading "~/" with the user's home directory.
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
+
+// wildmatch reports whether name matches a glob pattern in which "*" and "?" do not cross "/"
+// while "**" does.
+func wildmatch(pattern, name string, foldCase bool) bool {
+	var expr strings.Builder
+	if foldCase {
+		expr.WriteString("(?i)")
+	}
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return false
+	}
+	return re.MatchString(name)
+}
+
+// next returns the next key assignment in the file, or false at the end of the file.
+func (f *fileParser) next() (Entry, bool, error) {
+	for f.pos < len(f.data) {
+		c := f.data[f.pos]
+		switch {
+		case c == '\n':
+			f.line++
+			f.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			f.pos++
+		case c == '#' || c == ';':
+			f.skipLine()
+		case c == '[':
+			if err := f.parseHeader(); err != nil {
+				return Entry{}, false, err
+			}
+		case isKeyStart(c):
+			if f.section == "" {
+				return Entry{}, false, fmt.Errorf("key outside of any section")
+			}
+			return f.parseEntry()
+		default:
+			return Entry{}, false, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return Entry{}, false, nil
+}
+
+// skipLine advances to the end of the current line.
+func (f *fileParser) skipLine() {
+	for f.pos < len(f.data) && f.data[f.pos] != '\n' {
+		f.pos++
+	}
+}
+
+// parseHeader reads a section header: [section], [section "subsection"], or the deprecated
+// [section.subsection], whose subsection is case-insensitive.
+func (f *fileParser) parseHeader() error {
+	f.pos++ // Skip '['.
+
+	start := f.pos
+	for f.pos < len(f.data) && (isKeyChar(f.data[f.pos]) || f.data[f.pos] == '.') {
+		f.pos++
+	}
+	name := string(f.data[start:f.pos])
+	if name == "" {
+		return fmt.Errorf("empty section name")
+	}
+
+	f.subsection = ""
+	if section, subsection, found := strings.Cut(name, "."); found {
+		name, f.subsection = section, strings.ToLower(subsection)
+	}
+	f.section = strings.ToLower(name)
+
+	for f.pos < len(f.data) && (f.data[f.pos] == ' ' || f.data[f.pos] == '\t') {
+		f.pos++
+	}
+	if f.pos < len(f.data) && f.data[f.pos] == '"' {
+		subsection, err := f.parseQuotedSubsection()
+		if err != nil {
+			return err
+		}
+		f.subsection = subsection
+	}
+
+	if f.pos >= len(f.data) || f.data[f.pos] != ']' {
+		return fmt.Errorf("unterminated section header")
+	}
+	f.pos++
+	return nil
+}
+
+// parseQuotedSubsection reads a double-quoted subsection name, in which only \" and \\ are
+// escapes.
+func (f *fileParser) parseQuotedSubsection() (string, error) {
+	f.pos++ // Skip the opening quote.
+
+	var subsection strings.Builder
+	for f.pos < len(f.data) {
+		c := f.data[f.pos]
+		f.pos++
+		switch c {
+		case '"':
+			return subsection.String(), nil
+		case '\n':
+			return "", fmt.Errorf("newline in subsection name")
+		case '\\':
+			if f.pos < len(f.data) {
+				subsection.WriteByte(f.data[f.pos])
+				f.pos++
+			}
+		default:
+			subsection.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated subsection name")
+}
+
+// parseEntry reads "key", "key = value" or "key =" within the current section.
+func (f *fileParser) parseEntry() (Entry, bool, error) {
+	start := f.pos
+	for f.pos < len(f.data) && isKeyChar(f.data[f.pos]) {
+		f.pos++
+	}
+
+	entry := Entry{
+		Section:    f.section,
+		Subsection: f.subsection,
+		Key:        strings.ToLower(string(f.data[start:f.pos])),
+		Scope:      f.scope,
+		File:       f.path,
+	}
+
+	for f.pos < len(f.data) && (f.data[f.pos] == ' ' || f.data[f.pos] == '\t') {
+		f.pos++
+	}
+	if f.pos >= len(f.data) || f.data[f.pos] == '\n' || f.data[f.pos] == '\r' ||
+		f.data[f.pos] == '#' || f.data[f.pos] == ';' {
+		entry.NoValue = true
+		f.skipLine()
+		return entry, true, nil
+	}
+	if f.data[f.pos] != '=' {
+		return Entry{}, false, fmt.Errorf("invalid key %q", entry.Key)
+	}
+	f.pos++
+
+	value, err := f.parseValue()
+	if err != nil {
+		return Entry{}, false, err
+	}
+	entry.Value = value
+	return entry, true, nil
+}
+
+// parseValue reads a value up to the end of the line, honoring double quotes, backslash escapes,
+// line continuations and trailing comments. Whitespace outside quotes is trimmed at both ends.
+// See: https://git-scm.com/docs/git-config#_syntax
+func (f *fileParser) parseValue() (string, error) {
+	var value strings.Builder
+	var pendingSpace strings.Builder
+	quoted := false
+	started := false
+
+	for f.pos < len(f.data) {
+		c := f.data[f.pos]
+		f.pos++
+
+		switch {
+		case c == '\n':
+			if quoted {
+				return "", fmt.Errorf("unterminated quoted value")
+			}
+			f.line++
+			return value.String(), nil
+		case !quoted && (c == '#' || c == ';'):
+			f.skipLine()
+			return value.String(), nil
+		case !quoted && (c == ' ' || c == '\t' || c == '\r'):
+			if started {
+				pendingSpace.WriteByte(c)
+			}
+			continue
+		case c == '"':
+			quoted = !quoted
+			started = true
+			continue
+		case c == '\\':
+			if f.pos >= len(f.data) {
+				return "", fmt.Errorf("dangling backslash")
+			}
+			escaped := f.data[f.pos]
+			f.pos++
+			switch escaped {
+			case '\n':
+				f.line++
+				continue
+			case '\r':
+				if f.pos < len(f.data) && f.data[f.pos] == '\n' {
+					f.pos++
+					f.line++
+					continue
+				}
+				return "", fmt.Errorf("invalid escape \\r")
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case '\\', '"':
+				c = escaped
+			default:
+				return "", fmt.Errorf("invalid escape \\%c", escaped)
+			}
+		}
+
+		value.WriteString(pendingSpace.String())
+		pendingSpace.Reset()
+		value.WriteByte(c)
+		started = true
+	}
+
+	if quoted {
+		return "", fmt.Errorf("unterminated quoted value")
+	}
+	return value.String(), nil
+}
+
+// isKeyStart reports whether c may begin a key, which must start with a letter.
+func isKeyStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isKeyChar reports whether c may appear in a key or section name.
+func isKeyChar(c byte) bool {
+	return isKeyStart(c) || (c >= '0' && c <= '9') || c == '-'
+}
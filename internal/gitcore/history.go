@@ -0,0 +1,209 @@
+package gitcore
+
+import (
+	"container/heap"
+)
+
+// HistoryOptions configures a path-limited history walk.
+// Follow tracks a single file across renames, like git log --follow.
+// Limit stops the walk after that many entries; zero means no limit.
+type HistoryOptions struct {
+	Follow bool
+	Limit  int
+}
+
+// HistoryEntry is a commit that changed the tracked path, along with the change it made.
+type HistoryEntry struct {
+	Commit *Commit    `json:"commit"`
+	Change FileChange `json:"change"`
+}
+
+// historyItem is a commit to visit and the path the tracked file has in it.
+type historyItem struct {
+	commit *Commit
+	path   string
+}
+
+// historyQueue orders items from the most recently committed to the oldest.
+type historyQueue []historyItem
+
+func (q historyQueue) Len() int { return len(q) }
+func (q historyQueue) Less(i, j int) bool {
+	return q[i].commit.Committer.When.After(q[j].commit.Committer.When)
+}
+func (q historyQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *historyQueue) Push(x any)   { *q = append(*q, x.(historyItem)) }
+func (q *historyQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// pathState is the entry at the tracked path in one commit, if any.
+type pathState struct {
+	entry  TreeEntry
+	exists bool
+}
+
+// sameAs reports whether two states are indistinguishable, making a commit TREESAME to a parent.
+func (s pathState) sameAs(other pathState) bool {
+	if s.exists != other.exists {
+		return false
+	}
+	return !s.exists || (s.entry.ID == other.entry.ID && s.entry.Mode == other.entry.Mode)
+}
+
+// FileHistory walks history backwards from start and returns the commits that changed the file
+// or directory at filePath, newest first.
+// Like git log's default history simplification, a merge that matches one of its parents at the
+// path is skipped and only that parent is followed; lines of history that did not touch the path
+// are never reported.
+func (r *Repository) FileHistory(start Hash, filePath string, opts HistoryOptions) ([]HistoryEntry, error) {
+	commit, err := r.commit(start)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []HistoryEntry
+	var queue historyQueue
+	seen := make(map[string]bool)
+	push := func(commit *Commit, path string) {
+		key := suspectKey(commit.ID, path)
+		if !seen[key] {
+			seen[key] = true
+			heap.Push(&queue, historyItem{commit: commit, path: path})
+		}
+	}
+	push(commit, filePath)
+
+	for queue.Len() > 0 {
+		if opts.Limit > 0 && len(entries) >= opts.Limit {
+			break
+		}
+		item := heap.Pop(&queue).(historyItem)
+
+		current, err := r.pathState(item.commit, item.path)
+		if err != nil {
+			return nil, err
+		}
+
+		var parents []*Commit
+		var parentStates []pathState
+		var treesame *Commit
+		for _, parentID := range item.commit.Parents {
+			parent, err := r.commit(parentID)
+			if err != nil {
+				// Missing parents mark the edge of available history.
+				continue
+			}
+			state, err := r.pathState(parent, item.path)
+			if err != nil {
+				return nil, err
+			}
+			if current.sameAs(state) {
+				treesame = parent
+				break
+			}
+			parents = append(parents, parent)
+			parentStates = append(parentStates, state)
+		}
+
+		if treesame != nil {
+			push(treesame, item.path)
+			continue
+		}
+		if len(parents) == 0 {
+			if current.exists {
+				entries = append(entries, HistoryEntry{
+					Commit: item.commit,
+					Change: pathChange(item.path, pathState{}, current),
+				})
+			}
+			continue
+		}
+
+		change := pathChange(item.path, parentStates[0], current)
+		for i, parent := range parents {
+			parentPath := item.path
+			if opts.Follow && current.exists && !parentStates[i].exists {
+				rename, found, err := r.findRename(parent, item.commit, item.path)
+				if err != nil {
+					return nil, err
+				}
+				if found {
+					parentPath = rename.OldPath
+					if i == 0 {
+						change = rename
+					}
+				}
+			}
+			push(parent, parentPath)
+		}
+		entries = append(entries, HistoryEntry{Commit: item.commit, Change: change})
+	}
+
+	// Commits loaded from the commit-graph lack the metadata callers want to display.
+	for i, entry := range entries {
+		if entry.Commit.Partial {
+			commit, err := r.Commit(entry.Commit.ID)
+			if err != nil {
+				return nil, err
+			}
+			entries[i].Commit = commit
+		}
+	}
+
+	return entries, nil
+}
+
+// pathState looks up the entry at path in a commit's tree.
+func (r *Repository) pathState(commit *Commit, path string) (pathState, error) {
+	entry, exists, err := r.findTreeEntry(commit.Tree, path)
+	if err != nil {
+		return pathState{}, err
+	}
+	return pathState{entry: entry, exists: exists}, nil
+}
+
+// pathChange describes how the entry at path differs between two states.
+func pathChange(path string, before, after pathState) FileChange {
+	change := FileChange{Path: path}
+	if before.exists {
+		change.OldMode, change.OldHash = before.entry.Mode, before.entry.ID
+	}
+	if after.exists {
+		change.NewMode, change.NewHash = after.entry.Mode, after.entry.ID
+	}
+
+	switch {
+	case !before.exists:
+		change.Type = ChangeAdded
+	case !after.exists:
+		change.Type = ChangeDeleted
+	case before.entry.Mode.format() != after.entry.Mode.format():
+		change.Type = ChangeTypeChanged
+	default:
+		change.Type = ChangeModified
+	}
+	return change
+}
+
+// findRename reports the rename or copy that produced path in commit, relative to parent.
+func (r *Repository) findRename(parent, commit *Commit, path string) (FileChange, bool, error) {
+	changes, err := r.DiffTrees(parent.Tree, commit.Tree)
+	if err != nil {
+		return FileChange{}, false, err
+	}
+	changes, err = r.DetectRenames(changes, DefaultRenameOptions())
+	if err != nil {
+		return FileChange{}, false, err
+	}
+
+	for _, change := range changes {
+		if change.Path == path && (change.Type == ChangeRenamed || change.Type == ChangeCopied) {
+			return change, true, nil
+		}
+	}
+	return FileChange{}, false, nil
+}
@@ -0,0 +1,656 @@
+package gitcore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// DiffAlgorithm selects the strategy used to match lines between two files.
+// See: https://git-scm.com/docs/git-diff#Documentation/git-diff.txt---diff-algorithmpatienceminimalhistogrammyers
+type DiffAlgorithm string
+
+const (
+	DiffMyers     DiffAlgorithm = "myers"
+	DiffPatience  DiffAlgorithm = "patience"
+	DiffHistogram DiffAlgorithm = "histogram"
+)
+
+// WhitespaceMode controls which whitespace differences are ignored when comparing lines.
+type WhitespaceMode int
+
+const (
+	WhitespaceExact        WhitespaceMode = iota // Compare lines byte for byte.
+	IgnoreWhitespaceAtEOL                        // Like git diff --ignore-space-at-eol.
+	IgnoreWhitespaceChange                       // Like git diff -b.
+	IgnoreAllWhitespace                          // Like git diff -w.
+)
+
+// histogramMaxChain is the occurrence count above which the histogram algorithm gives up on a
+// region and falls back to Myers, mirroring git's MAX_CHAIN_LENGTH.
+const histogramMaxChain = 64
+
+// whitespaceRunRe matches a run of whitespace that -b treats as equivalent to any other run.
+var whitespaceRunRe = regexp.MustCompile(`\s+`)
+
+// DiffOptions configures line-level diff generation.
+// Renames applies when diffing whole commits rather than individual blobs.
+type DiffOptions struct {
+	Algorithm  DiffAlgorithm
+	Context    int
+	Whitespace WhitespaceMode
+	Renames    RenameOptions
+}
+
+// DefaultDiffOptions returns the options git diff uses by default.
+func DefaultDiffOptions() DiffOptions {
+	return DiffOptions{
+		Algorithm:  DiffMyers,
+		Context:    3,
+		Whitespace: WhitespaceExact,
+		Renames:    DefaultRenameOptions(),
+	}
+}
+
+// LineKind denotes the role of a line within a hunk.
+type LineKind string
+
+const (
+	LineContext LineKind = "context"
+	LineAdded   LineKind = "added"
+	LineDeleted LineKind = "deleted"
+)
+
+// DiffLine is a single line of a hunk, stored without its trailing newline.
+// NoNewline is set on the last line of a file that does not end in a newline.
+type DiffLine struct {
+	Kind      LineKind `json:"kind"`
+	Text      string   `json:"text"`
+	NoNewline bool     `json:"noNewline,omitempty"`
+}
+
+// Hunk is a contiguous group of changed lines with surrounding context.
+// Line numbers are 1-based, following the unified diff format.
+type Hunk struct {
+	OldStart int        `json:"oldStart"`
+	OldLines int        `json:"oldLines"`
+	NewStart int        `json:"newStart"`
+	NewLines int        `json:"newLines"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+// Header returns the hunk's "@@ -a,b +c,d @@" range line.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+// hunkRange formats one side of a hunk header, omitting the count when it is one.
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// BlobDiff is the line-level difference between two versions of a file.
+// Binary diffs carry no hunks.
+type BlobDiff struct {
+	OldHash Hash   `json:"oldHash"`
+	NewHash Hash   `json:"newHash"`
+	Binary  bool   `json:"binary"`
+	Hunks   []Hunk `json:"hunks"`
+}
+
+// Unified renders the diff in git's unified patch format, labelling the sides with the given paths.
+// An empty path denotes a side that does not exist, such as the old side of an added file.
+func (d *BlobDiff) Unified(oldPath, newPath string) string {
+	oldLabel, newLabel := "/dev/null", "/dev/null"
+	if oldPath != "" {
+		oldLabel = "a/" + oldPath
+	}
+	if newPath != "" {
+		newLabel = "b/" + newPath
+	}
+
+	var buf strings.Builder
+	if d.Binary {
+		fmt.Fprintf(&buf, "Binary files %s and %s differ\n", oldLabel, newLabel)
+		return buf.String()
+	}
+	if len(d.Hunks) == 0 {
+		return ""
+	}
+
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldLabel, newLabel)
+	for _, hunk := range d.Hunks {
+		buf.WriteString(hunk.Header())
+		buf.WriteByte('\n')
+		for _, line := range hunk.Lines {
+			switch line.Kind {
+			case LineAdded:
+				buf.WriteByte('+')
+			case LineDeleted:
+				buf.WriteByte('-')
+			default:
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(line.Text)
+			buf.WriteByte('\n')
+			if line.NoNewline {
+				buf.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+	return buf.String()
+}
+
+// DiffBlobs computes the line-level diff between two blobs.
+// An empty hash on either side stands for an empty file.
+func (r *Repository) DiffBlobs(oldID, newID Hash, opts DiffOptions) (*BlobDiff, error) {
+	diff := &BlobDiff{OldHash: oldID, NewHash: newID}
+
+	oldContent, oldBinary, err := r.readBlobContent(oldID)
+	if err != nil {
+		return nil, err
+	}
+	newContent, newBinary, err := r.readBlobContent(newID)
+	if err != nil {
+		return nil, err
+	}
+
+	if oldBinary || newBinary {
+		diff.Binary = !bytes.Equal(oldContent, newContent)
+		return diff, nil
+	}
+
+	diff.Hunks = DiffLines(oldContent, newContent, opts)
+	return diff, nil
+}
+
+// readBlobContent reads a whole blob into memory, reporting whether it is binary.
+// An empty hash yields empty content.
+func (r *Repository) readBlobContent(id Hash) ([]byte, bool, error) {
+	if id == "" {
+		return nil, false, nil
+	}
+
+	blob, err := r.Blob(id)
+	if err != nil {
+		return nil, false, err
+	}
+	defer blob.Close()
+
+	content, err := io.ReadAll(blob)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read blob %s: %w", id, err)
+	}
+	return content, blob.Binary, nil
+}
+
+// DiffLines computes unified hunks between two texts.
+func DiffLines(oldContent, newContent []byte, opts DiffOptions) []Hunk {
+	oldLines, oldNoEOL := splitLines(oldContent)
+	newLines, newNoEOL := splitLines(newContent)
+
+	interner := make(map[string]int)
+	d := &lineDiffer{
+		a:         internLines(oldLines, oldNoEOL, opts.Whitespace, interner),
+		b:         internLines(newLines, newNoEOL, opts.Whitespace, interner),
+		algorithm: opts.Algorithm,
+	}
+	d.diff(0, len(d.a), 0, len(d.b))
+
+	script := d.editScript()
+	return buildHunks(script, oldLines, newLines, oldNoEOL, newNoEOL, opts.Context)
+}
+
+// matchLines maps each line of newContent to the line of oldContent it was carried over from,
+// or -1 for lines that were added. Lines are compared exactly using Myers.
+func matchLines(oldContent, newContent []byte) []int {
+	oldLines, oldNoEOL := splitLines(oldContent)
+	newLines, newNoEOL := splitLines(newContent)
+
+	interner := make(map[string]int)
+	d := &lineDiffer{
+		a:         internLines(oldLines, oldNoEOL, WhitespaceExact, interner),
+		b:         internLines(newLines, newNoEOL, WhitespaceExact, interner),
+		algorithm: DiffMyers,
+	}
+	d.diff(0, len(d.a), 0, len(d.b))
+
+	mapping := make([]int, len(newLines))
+	for i := range mapping {
+		mapping[i] = -1
+	}
+	for _, match := range d.matches {
+		mapping[match[1]] = match[0]
+	}
+	return mapping
+}
+
+// splitLines splits content into lines without their newlines.
+// Reports whether the final line is missing its terminating newline.
+func splitLines(content []byte) ([]string, bool) {
+	if len(content) == 0 {
+		return nil, false
+	}
+
+	text := string(content)
+	noEOL := !strings.HasSuffix(text, "\n")
+	text = strings.TrimSuffix(text, "\n")
+	return strings.Split(text, "\n"), noEOL
+}
+
+// internLines maps each line to an integer key so that lines equal under the whitespace mode
+// share a key. A final line without a newline never matches one that has a newline.
+func internLines(lines []string, noEOL bool, mode WhitespaceMode, interner map[string]int) []int {
+	keys := make([]int, len(lines))
+	for i, line := range lines {
+		key := normalizeWhitespace(line, mode)
+		if noEOL && i == len(lines)-1 {
+			key += "\x00"
+		}
+		id, found := interner[key]
+		if !found {
+			id = len(interner)
+			interner[key] = id
+		}
+		keys[i] = id
+	}
+	return keys
+}
+
+// normalizeWhitespace rewrites a line into its comparison form for the given mode.
+func normalizeWhitespace(line string, mode WhitespaceMode) string {
+	switch mode {
+	case IgnoreWhitespaceAtEOL:
+		return strings.TrimRightFunc(line, unicode.IsSpace)
+	case IgnoreWhitespaceChange:
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		return whitespaceRunRe.ReplaceAllString(line, " ")
+	case IgnoreAllWhitespace:
+		return strings.Join(strings.Fields(line), "")
+	default:
+		return line
+	}
+}
+
+// lineDiffer matches interned lines between a and b, recording matched pairs in order.
+type lineDiffer struct {
+	a, b      []int
+	algorithm DiffAlgorithm
+	matches   [][2]int
+}
+
+// diff matches lines in a[aLo:aHi] against b[bLo:bHi] using the configured algorithm.
+func (d *lineDiffer) diff(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.matches = append(d.matches, [2]int{aLo, bLo})
+		aLo, bLo = aLo+1, bLo+1
+	}
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-suffix-1] == d.b[bHi-suffix-1] {
+		suffix++
+	}
+
+	if aLo < aHi-suffix && bLo < bHi-suffix {
+		switch d.algorithm {
+		case DiffPatience:
+			d.patience(aLo, aHi-suffix, bLo, bHi-suffix)
+		case DiffHistogram:
+			d.histogram(aLo, aHi-suffix, bLo, bHi-suffix)
+		default:
+			d.myers(aLo, aHi-suffix, bLo, bHi-suffix)
+		}
+	}
+
+	for i := suffix; i > 0; i-- {
+		d.matches = append(d.matches, [2]int{aHi - i, bHi - i})
+	}
+}
+
+// myers finds the middle snake of the shortest edit script between the ranges and recurses on
+// either side of it, using linear space.
+// See: http://www.xmailserver.org/diff2.pdf
+func (d *lineDiffer) myers(aLo, aHi, bLo, bHi int) {
+	n, m := aHi-aLo, bHi-bLo
+	maxD := (n + m + 1) / 2
+	offset, length := maxD, 2*maxD+2
+
+	forward := make([]int, length)
+	backward := make([]int, length)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	delta := n - m
+	front := delta%2 != 0
+	var k1Start, k1End, k2Start, k2End int
+
+	for step := 0; step < maxD; step++ {
+		for k1 := -step + k1Start; k1 <= step-k1End; k1 += 2 {
+			k1Offset := offset + k1
+			var x1 int
+			if k1 == -step || (k1 != step && forward[k1Offset-1] < forward[k1Offset+1]) {
+				x1 = forward[k1Offset+1]
+			} else {
+				x1 = forward[k1Offset-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && d.a[aLo+x1] == d.b[bLo+y1] {
+				x1, y1 = x1+1, y1+1
+			}
+			forward[k1Offset] = x1
+
+			switch {
+			case x1 > n:
+				k1End += 2
+			case y1 > m:
+				k1Start += 2
+			case front:
+				k2Offset := offset + delta - k1
+				if k2Offset >= 0 && k2Offset < length && backward[k2Offset] != -1 {
+					if x1 >= n-backward[k2Offset] {
+						d.diff(aLo, aLo+x1, bLo, bLo+y1)
+						d.diff(aLo+x1, aHi, bLo+y1, bHi)
+						return
+					}
+				}
+			}
+		}
+
+		for k2 := -step + k2Start; k2 <= step-k2End; k2 += 2 {
+			k2Offset := offset + k2
+			var x2 int
+			if k2 == -step || (k2 != step && backward[k2Offset-1] < backward[k2Offset+1]) {
+				x2 = backward[k2Offset+1]
+			} else {
+				x2 = backward[k2Offset-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && d.a[aHi-x2-1] == d.b[bHi-y2-1] {
+				x2, y2 = x2+1, y2+1
+			}
+			backward[k2Offset] = x2
+
+			switch {
+			case x2 > n:
+				k2End += 2
+			case y2 > m:
+				k2Start += 2
+			case !front:
+				k1Offset := offset + delta - k2
+				if k1Offset >= 0 && k1Offset < length && forward[k1Offset] != -1 {
+					x1 := forward[k1Offset]
+					y1 := offset + x1 - k1Offset
+					if x1 >= n-x2 {
+						d.diff(aLo, aLo+x1, bLo, bLo+y1)
+						d.diff(aLo+x1, aHi, bLo+y1, bHi)
+						return
+					}
+				}
+			}
+		}
+	}
+
+	// No common subsequence: every line is either deleted or added.
+}
+
+// patience anchors the diff on lines that occur exactly once on each side, matches the longest
+// increasing sequence of those anchors, and recurses between them. Ranges without unique common
+// lines fall back to Myers.
+// See: https://bramcohen.livejournal.com/73318.html
+func (d *lineDiffer) patience(aLo, aHi, bLo, bHi int) {
+	type occurrence struct {
+		aCount, bCount int
+		aIdx, bIdx     int
+	}
+	occurrences := make(map[int]*occurrence)
+	for i := aLo; i < aHi; i++ {
+		occ := occurrences[d.a[i]]
+		if occ == nil {
+			occ = &occurrence{}
+			occurrences[d.a[i]] = occ
+		}
+		occ.aCount++
+		occ.aIdx = i
+	}
+	for j := bLo; j < bHi; j++ {
+		if occ := occurrences[d.b[j]]; occ != nil {
+			occ.bCount++
+			occ.bIdx = j
+		}
+	}
+
+	// Unique common lines in order of appearance in a, identified by their index in b.
+	var candidates [][2]int
+	for i := aLo; i < aHi; i++ {
+		if occ := occurrences[d.a[i]]; occ.aCount == 1 && occ.bCount == 1 {
+			candidates = append(candidates, [2]int{i, occ.bIdx})
+		}
+	}
+	if len(candidates) == 0 {
+		d.myers(aLo, aHi, bLo, bHi)
+		return
+	}
+
+	anchors := longestIncreasing(candidates)
+	prevA, prevB := aLo, bLo
+	for _, anchor := range anchors {
+		d.diff(prevA, anchor[0], prevB, anchor[1])
+		d.matches = append(d.matches, anchor)
+		prevA, prevB = anchor[0]+1, anchor[1]+1
+	}
+	d.diff(prevA, aHi, prevB, bHi)
+}
+
+// longestIncreasing returns the longest subsequence of pairs whose second elements increase,
+// using patience sorting. Pairs must already be ordered by their first element.
+func longestIncreasing(pairs [][2]int) [][2]int {
+	var piles []int // Index into pairs of the top card of each pile.
+	prev := make([]int, len(pairs))
+
+	for i, pair := range pairs {
+		lo, hi := 0, len(piles)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if pairs[piles[mid]][1] < pair[1] {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		prev[i] = -1
+		if lo > 0 {
+			prev[i] = piles[lo-1]
+		}
+		if lo == len(piles) {
+			piles = append(piles, i)
+		} else {
+			piles[lo] = i
+		}
+	}
+
+	result := make([][2]int, len(piles))
+	for i, k := len(piles)-1, piles[len(piles)-1]; i >= 0; i, k = i-1, prev[k] {
+		result[i] = pairs[k]
+	}
+	return result
+}
+
+// histogram splits the ranges around the longest common region whose lines occur least often in
+// a, then recurses on either side. Ranges where every common line is too frequent fall back to
+// Myers.
+// See: https://github.com/git/git/blob/master/xdiff/xhistogram.c
+func (d *lineDiffer) histogram(aLo, aHi, bLo, bHi int) {
+	positions := make(map[int][]int)
+	for i := aLo; i < aHi; i++ {
+		positions[d.a[i]] = append(positions[d.a[i]], i)
+	}
+
+	bestCount := histogramMaxChain + 1
+	var bestA, bestB, bestLen int
+	tooFrequent := false
+
+	for j := bLo; j < bHi; {
+		next := j + 1
+		candidates := positions[d.b[j]]
+		if len(candidates) > histogramMaxChain {
+			tooFrequent = true
+			j = next
+			continue
+		}
+		if len(candidates) > bestCount {
+			j = next
+			continue
+		}
+
+		for _, i := range candidates {
+			as, bs := i, j
+			for as > aLo && bs > bLo && d.a[as-1] == d.b[bs-1] {
+				as, bs = as-1, bs-1
+			}
+			ae, be := i+1, j+1
+			for ae < aHi && be < bHi && d.a[ae] == d.b[be] {
+				ae, be = ae+1, be+1
+			}
+
+			regionCount := len(candidates)
+			for k := as; k < ae; k++ {
+				if c := len(positions[d.a[k]]); c < regionCount {
+					regionCount = c
+				}
+			}
+			if ae-as > bestLen || regionCount < bestCount {
+				bestA, bestB, bestLen, bestCount = as, bs, ae-as, regionCount
+			}
+			if be > next {
+				next = be
+			}
+		}
+		j = next
+	}
+
+	if bestLen == 0 {
+		if tooFrequent {
+			d.myers(aLo, aHi, bLo, bHi)
+		}
+		return
+	}
+
+	d.diff(aLo, bestA, bLo, bestB)
+	for k := 0; k < bestLen; k++ {
+		d.matches = append(d.matches, [2]int{bestA + k, bestB + k})
+	}
+	d.diff(bestA+bestLen, aHi, bestB+bestLen, bHi)
+}
+
+// editOp is one step of an edit script: a kept, deleted or added line.
+type editOp struct {
+	kind   LineKind
+	oldIdx int
+	newIdx int
+}
+
+// editScript expands the recorded matches into a full sequence of edit operations.
+func (d *lineDiffer) editScript() []editOp {
+	var script []editOp
+	i, j := 0, 0
+	emitUntil := func(aEnd, bEnd int) {
+		for ; i < aEnd; i++ {
+			script = append(script, editOp{kind: LineDeleted, oldIdx: i, newIdx: j})
+		}
+		for ; j < bEnd; j++ {
+			script = append(script, editOp{kind: LineAdded, oldIdx: i, newIdx: j})
+		}
+	}
+
+	for _, match := range d.matches {
+		emitUntil(match[0], match[1])
+		script = append(script, editOp{kind: LineContext, oldIdx: i, newIdx: j})
+		i, j = i+1, j+1
+	}
+	emitUntil(len(d.a), len(d.b))
+	return script
+}
+
+// buildHunks groups an edit script into hunks, keeping up to context unchanged lines around each
+// change and merging hunks whose context would overlap.
+func buildHunks(script []editOp, oldLines, newLines []string, oldNoEOL, newNoEOL bool, context int) []Hunk {
+	if context < 0 {
+		context = 0
+	}
+
+	var hunks []Hunk
+	for start := 0; start < len(script); {
+		if script[start].kind == LineContext {
+			start++
+			continue
+		}
+
+		// Extend the hunk until a run of more than 2*context unchanged lines is found.
+		end := start
+		for end < len(script) {
+			if script[end].kind != LineContext {
+				end++
+				continue
+			}
+			run := end
+			for run < len(script) && script[run].kind == LineContext {
+				run++
+			}
+			if run == len(script) || run-end > 2*context {
+				break
+			}
+			end = run
+		}
+
+		lo := max(start-context, 0)
+		hi := min(end+context, len(script))
+		hunks = append(hunks, makeHunk(script[lo:hi], oldLines, newLines, oldNoEOL, newNoEOL))
+		start = hi
+	}
+
+	return hunks
+}
+
+// makeHunk converts a slice of an edit script into a Hunk.
+func makeHunk(ops []editOp, oldLines, newLines []string, oldNoEOL, newNoEOL bool) Hunk {
+	hunk := Hunk{
+		OldStart: ops[0].oldIdx,
+		NewStart: ops[0].newIdx,
+	}
+
+	for _, op := range ops {
+		line := DiffLine{Kind: op.kind}
+		switch op.kind {
+		case LineDeleted:
+			line.Text = oldLines[op.oldIdx]
+			line.NoNewline = oldNoEOL && op.oldIdx == len(oldLines)-1
+			hunk.OldLines++
+		case LineAdded:
+			line.Text = newLines[op.newIdx]
+			line.NoNewline = newNoEOL && op.newIdx == len(newLines)-1
+			hunk.NewLines++
+		default:
+			line.Text = newLines[op.newIdx]
+			line.NoNewline = newNoEOL && op.newIdx == len(newLines)-1
+			hunk.OldLines++
+			hunk.NewLines++
+		}
+		hunk.Lines = append(hunk.Lines, line)
+	}
+
+	// Unified diffs number an empty range by the line preceding it.
+	if hunk.OldLines > 0 {
+		hunk.OldStart++
+	}
+	if hunk.NewLines > 0 {
+		hunk.NewStart++
+	}
+	return hunk
+}
@@ -0,0 +1,162 @@
+package gitcore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Multi-pack-index chunk identifiers.
+// See: https://git-scm.com/docs/gitformat-pack#_multi_pack_index_midx_files_have_the_following_format
+const (
+	chunkPackNames    = 0x504e414d // "PNAM"
+	chunkObjectOffset = 0x4f4f4646 // "OOFF"
+	chunkLargeOffset  = 0x4c4f4646 // "LOFF"
+)
+
+// midxLargeOffsetFlag marks an offset that indexes the large offset chunk.
+const midxLargeOffsetFlag = 0x80000000
+
+// MultiPackIndex maps object hashes to their locations across all the packs it covers.
+type MultiPackIndex struct {
+	path      string
+	hashSize  int
+	packPaths []string
+
+	fanout        []byte
+	oidLookup     []byte
+	objectOffsets []byte
+	largeOffsets  []byte
+}
+
+// FindObject looks up the pack file and offset of an object by its hash.
+// Returns the pack path, offset and true if found, otherwise returns false.
+func (m *MultiPackIndex) FindObject(id Hash) (string, int64, bool) {
+	raw, err := hex.DecodeString(string(id))
+	if err != nil || len(raw) != m.hashSize {
+		return "", 0, false
+	}
+
+	pos, found := searchFanout(m.fanout, m.oidLookup, m.hashSize, raw)
+	if !found {
+		return "", 0, false
+	}
+
+	entry := m.objectOffsets[pos*8:]
+	packID := binary.BigEndian.Uint32(entry)
+	offset := uint64(binary.BigEndian.Uint32(entry[4:]))
+	if offset&midxLargeOffsetFlag != 0 {
+		i := int(offset &^ midxLargeOffsetFlag)
+		if (i+1)*8 > len(m.largeOffsets) {
+			return "", 0, false
+		}
+		offset = binary.BigEndian.Uint64(m.largeOffsets[i*8:])
+	}
+	if int(packID) >= len(m.packPaths) {
+		return "", 0, false
+	}
+	return m.packPaths[packID], int64(offset), true
+}
+
+// covers reports whether the multi-pack-index includes the pack with the given index file name.
+func (m *MultiPackIndex) covers(idxName string) bool {
+	packPath := filepath.Join(filepath.Dir(m.path), strings.TrimSuffix(idxName, ".idx")+".pack")
+	for _, path := range m.packPaths {
+		if path == packPath {
+			return true
+		}
+	}
+	return false
+}
+
+// loadMultiPackIndex reads objects/pack/multi-pack-index. Returns nil if the file does not exist.
+func (r *Repository) loadMultiPackIndex(packDir string) (*MultiPackIndex, error) {
+	path := filepath.Join(packDir, "multi-pack-index")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 12 || string(data[:4]) != "MIDX" {
+		return nil, fmt.Errorf("invalid multi-pack-index signature")
+	}
+	if data[4] != 1 {
+		return nil, fmt.Errorf("unsupported multi-pack-index version %d", data[4])
+	}
+	hashVersion := map[ObjectFormat]byte{SHA1: 1, SHA256: 2}[r.objectFormat]
+	if data[5] != hashVersion {
+		return nil, fmt.Errorf("multi-pack-index does not use %s", r.objectFormat)
+	}
+	if data[7] != 0 {
+		return nil, fmt.Errorf("incremental multi-pack-index chains are not supported")
+	}
+	numPacks := int(binary.BigEndian.Uint32(data[8:]))
+
+	chunks, err := readChunkTable(data, 12, int(data[6]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid multi-pack-index: %w", err)
+	}
+
+	m := &MultiPackIndex{
+		path:          path,
+		hashSize:      r.objectFormat.Size(),
+		fanout:        chunks[chunkOIDFanout],
+		oidLookup:     chunks[chunkOIDLookup],
+		objectOffsets: chunks[chunkObjectOffset],
+		largeOffsets:  chunks[chunkLargeOffset],
+	}
+	if len(m.fanout) != 256*4 || m.oidLookup == nil || m.objectOffsets == nil || chunks[chunkPackNames] == nil {
+		return nil, fmt.Errorf("multi-pack-index is missing required chunks")
+	}
+	numObjects := int(binary.BigEndian.Uint32(m.fanout[255*4:]))
+	if len(m.oidLookup) < numObjects*m.hashSize || len(m.objectOffsets) < numObjects*8 {
+		return nil, fmt.Errorf("multi-pack-index is truncated")
+	}
+
+	// Pack names are NUL-terminated, possibly followed by alignment padding.
+	for _, name := range bytes.Split(chunks[chunkPackNames], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		packPath := filepath.Join(packDir, strings.TrimSuffix(string(name), ".idx")+".pack")
+		if _, err := os.Stat(packPath); err != nil {
+			return nil, fmt.Errorf("multi-pack-index refers to missing pack %s", name)
+		}
+		m.packPaths = append(m.packPaths, packPath)
+	}
+	if len(m.packPaths) != numPacks {
+		return nil, fmt.Errorf("multi-pack-index lists %d packs, expected %d", len(m.packPaths), numPacks)
+	}
+
+	return m, nil
+}
+
+// searchFanout binary searches a sorted table of raw object names, narrowed by a 256-entry
+// fanout table, and returns the position of the name if present.
+func searchFanout(fanout, names []byte, hashSize int, raw []byte) (int, bool) {
+	var lo uint32
+	if raw[0] > 0 {
+		lo = binary.BigEndian.Uint32(fanout[(int(raw[0])-1)*4:])
+	}
+	hi := binary.BigEndian.Uint32(fanout[int(raw[0])*4:])
+
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start := int(mid) * hashSize
+		switch cmp := bytes.Compare(names[start:start+hashSize], raw); {
+		case cmp == 0:
+			return int(mid), true
+		case cmp < 0:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package gitcore
+
+import "os"
+
+// mapFile reads a file into memory on platforms without mmap support.
+func mapFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+// unmapFile is a no-op; the data is released by the garbage collector.
+func unmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,173 @@
+package gitcore
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// notesRefPrefix is the namespace of refs holding git notes.
+const notesRefPrefix = "refs/notes/"
+
+// loadNotesRefs collects refs/notes/*. Notes refs are removed from the ordinary refs, since their
+// history records edits to notes rather than anything that belongs in the commit graph.
+// See: https://git-scm.com/docs/git-notes
+func (r *Repository) loadNotesRefs() {
+	for refName, hash := range r.refs {
+		if !strings.HasPrefix(refName, notesRefPrefix) {
+			continue
+		}
+		delete(r.refs, refName)
+		if r.notesRefs == nil {
+			r.notesRefs = make(map[string]Hash)
+		}
+		r.notesRefs[refName] = hash
+	}
+}
+
+// loadNotes indexes the notes tree of every notes ref and attaches the notes to loaded commits.
+// It assumes that all commits have already been loaded.
+func (r *Repository) loadNotes() {
+	r.notes = make(map[string]map[Hash]Hash, len(r.notesRefs))
+	for refName, id := range r.notesRefs {
+		object, err := r.readObject(id)
+		if err != nil {
+			// Log the error but continue with other potentially valid notes refs.
+			log.Printf("error reading notes ref %s: %v", refName, err)
+			continue
+		}
+		commit, ok := object.(*Commit)
+		if !ok {
+			log.Printf("notes ref %s does not point to a commit", refName)
+			continue
+		}
+
+		index := make(map[Hash]Hash)
+		if err := r.indexNotesTree(commit.Tree, "", index); err != nil {
+			log.Printf("error reading notes tree of %s: %v", refName, err)
+			continue
+		}
+		r.notes[refName] = index
+	}
+
+	for _, commit := range r.commits {
+		r.attachNotes(commit)
+	}
+}
+
+// indexNotesTree maps annotated objects to note blobs. Notes are named after the object they
+// annotate, possibly split into fanout directories of two hex digits each (ab/cdef...), so a
+// note's object name is the concatenation of the path components leading to it.
+func (r *Repository) indexNotesTree(treeID Hash, prefix string, index map[Hash]Hash) error {
+	tree, err := r.Tree(treeID)
+	if err != nil {
+		return err
+	}
+
+	hexSize := r.objectFormat.HexSize()
+	for _, entry := range tree.Entries {
+		name := prefix + entry.Name
+		switch {
+		case entry.Mode == ModeTree && len(entry.Name) == 2 && len(name) < hexSize:
+			if err := r.indexNotesTree(entry.ID, name, index); err != nil {
+				return err
+			}
+		case entry.Kind == BlobObject && len(name) == hexSize:
+			if id, err := NewHash(name); err == nil {
+				index[id] = entry.ID
+			}
+		}
+		// Anything else is not a note, e.g. a file added to the notes tree by hand.
+	}
+
+	return nil
+}
+
+// changedNotes returns the objects whose note in any notes ref differs from old, including
+// objects whose notes were added or removed.
+func (r *Repository) changedNotes(old *Repository) []Hash {
+	changed := make(map[Hash]bool)
+	compare := func(a, b map[string]map[Hash]Hash) {
+		for refName, index := range a {
+			for id, blobID := range index {
+				if b[refName][id] != blobID {
+					changed[id] = true
+				}
+			}
+		}
+	}
+	compare(r.notes, old.notes)
+	compare(old.notes, r.notes)
+
+	ids := make([]Hash, 0, len(changed))
+	for id := range changed {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// attachNotes fills in a commit's notes from every notes ref that annotates it.
+func (r *Repository) attachNotes(commit *Commit) {
+	for refName, index := range r.notes {
+		blobID, found := index[commit.ID]
+		if !found {
+			continue
+		}
+		text, err := r.readNote(blobID)
+		if err != nil {
+			log.Printf("error reading note for %s: %v", commit.ID.Short(), err)
+			continue
+		}
+		if commit.Notes == nil {
+			commit.Notes = make(map[string]string)
+		}
+		commit.Notes[strings.TrimPrefix(refName, notesRefPrefix)] = text
+	}
+}
+
+// readNote reads the text of a note blob.
+func (r *Repository) readNote(id Hash) (string, error) {
+	blob, err := r.Blob(id)
+	if err != nil {
+		return "", err
+	}
+	defer blob.Close()
+
+	content, err := io.ReadAll(blob)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// Notes returns the note attached to an object by a notes ref, or the empty string if there is
+// none. ref may be given in full (refs/notes/commits) or by its short name (commits).
+func (r *Repository) Notes(ref string, id Hash) (string, error) {
+	if !strings.HasPrefix(ref, notesRefPrefix) {
+		ref = notesRefPrefix + ref
+	}
+
+	index, found := r.notes[ref]
+	if !found {
+		if _, exists := r.notesRefs[ref]; exists {
+			return "", fmt.Errorf("failed to read notes ref %s", ref)
+		}
+		return "", fmt.Errorf("notes ref not found: %s", ref)
+	}
+
+	blobID, found := index[id]
+	if !found {
+		return "", nil
+	}
+	return r.readNote(blobID)
+}
+
+// NotesRefs returns the names of all notes refs.
+func (r *Repository) NotesRefs() []string {
+	result := make([]string, 0, len(r.notesRefs))
+	for refName := range r.notesRefs {
+		result = append(result, refName)
+	}
+	return result
+}
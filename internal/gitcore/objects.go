@@ -8,18 +8,47 @@ import (
 	"io"
 	"log"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 )
 
 // loadObjects loads all Git objects into the object store.
-// It traverses all references and their histories.
+// It traverses all references and their histories, reading commits from the commit-graph when
+// one is available rather than inflating each commit object.
 // It assumes that all references have already been loaded.
 func (r *Repository) loadObjects() error {
+	graph, err := r.loadCommitGraph()
+	if err != nil {
+		// A broken commit-graph only costs speed, since every commit can still be read directly.
+		log.Printf("ignoring commit-graph: %v", err)
+	}
+	if graph != nil && r.rewritesHistory() {
+		// Like git, ignore the commit-graph when replace refs or grafts alter parents it recorded.
+		graph = nil
+	}
+	r.commitGraph = graph
+
+	if r.shallow, err = r.loadShallow(); err != nil {
+		return fmt.Errorf("failed to read shallow file: %w", err)
+	}
+
 	visited := make(map[Hash]bool)
 	for _, ref := range r.refs {
 		r.traverseObjects(ref, visited)
 	}
+	// A HEAD need not be named by a loaded ref: it may be detached, or its branch may have been
+	// filtered out by Options.Refs. Its commit is still shown, along with its history.
+	if r.head != "" {
+		r.traverseObjects(r.head, visited)
+	}
+	for _, worktree := range r.worktrees {
+		if worktree.Head != "" {
+			r.traverseObjects(worktree.Head, visited)
+		}
+	}
+	r.loadStashes(visited)
+	r.loadNotes()
+	r.loadTags()
 
 	return nil
 }
@@ -32,6 +61,17 @@ func (r *Repository) traverseObjects(ref Hash, visited map[Hash]bool) {
 	}
 	visited[ref] = true
 
+	if r.commitGraph != nil {
+		if pos, found := r.commitGraph.lookup(ref); found {
+			commit, err := r.commitGraph.commit(ref, pos)
+			if err == nil {
+				r.addCommit(commit, visited)
+				return
+			}
+			log.Printf("error reading commit-graph entry: %v", err)
+		}
+	}
+
 	object, err := r.readObject(ref)
 	if err != nil {
 		// Log the error but continue with other potentially valid objects.
@@ -41,11 +81,7 @@ func (r *Repository) traverseObjects(ref Hash, visited map[Hash]bool) {
 
 	switch object.Type() {
 	case CommitObject:
-		commit := object.(*Commit)
-		r.commits = append(r.commits, commit)
-		for _, parent := range commit.Parents {
-			r.traverseObjects(parent, visited)
-		}
+		r.addCommit(object.(*Commit), visited)
 	case TagObject:
 		tag := object.(*Tag)
 		r.tags = append(r.tags, tag)
@@ -56,10 +92,34 @@ func (r *Repository) traverseObjects(ref Hash, visited map[Hash]bool) {
 	}
 }
 
-// readObject parses an object from its hash.
-// It first attempts to read from loose objects, then falls back to pack files.
+// addCommit records a commit and traverses its parents. The parents of a shallow boundary commit
+// were never fetched, so the traversal stops there instead of failing to read them.
+func (r *Repository) addCommit(commit *Commit, visited map[Hash]bool) {
+	r.commits = append(r.commits, commit)
+	r.commitIndex[commit.ID] = commit
+	if r.shallow[commit.ID] {
+		commit.Shallow = true
+		return
+	}
+	for _, parent := range commit.Parents {
+		r.traverseObjects(parent, visited)
+	}
+}
+
+// readObject parses an object from its hash, substituting its replacement, if any, and applying
+// grafts to commits.
 func (r *Repository) readObject(id Hash) (Object, error) {
-	header, content, err := r.readLooseObject(id)
+	object, err := r.readStoredObject(r.replacementFor(id), id)
+	if commit, ok := object.(*Commit); ok {
+		r.applyGraft(commit)
+	}
+	return object, err
+}
+
+// readStoredObject parses the object stored under source, naming it id.
+// It first attempts to read from loose objects, then falls back to pack files.
+func (r *Repository) readStoredObject(source, id Hash) (Object, error) {
+	header, content, err := r.readLooseObject(source)
 	if err == nil {
 		switch {
 		case strings.HasPrefix(header, "commit"):
@@ -70,15 +130,17 @@ func (r *Repository) readObject(id Hash) (Object, error) {
 			if tag, err := r.parseTagBody(content, id); err == nil {
 				return tag, nil
 			}
+		case strings.HasPrefix(header, "tree"):
+			if tree, err := r.parseTreeBody(content, id); err == nil {
+				return tree, nil
+			}
 		default:
 			err = fmt.Errorf("unrecognized object: %q", header)
 		}
 	}
 
-	for _, packIndex := range r.packIndices {
-		if offset, found := packIndex.FindObject(id); found {
-			return r.readPackedObject(packIndex.PackFile(), offset, id)
-		}
+	if packPath, offset, found := r.findPackedObject(source); found {
+		return r.readPackedObject(packPath, offset, id)
 	}
 
 	// We didn't find the object in either packed or loose storage.
@@ -87,24 +149,12 @@ func (r *Repository) readObject(id Hash) (Object, error) {
 
 // readObjectData reads any object, loose or packed, and returns raw data.
 func (r *Repository) readObjectData(id Hash) ([]byte, byte, error) {
-	objectPath := filepath.Join(r.gitDir, "objects", string(id)[:2], string(id)[2:])
-	if _, err := os.Stat(objectPath); err == nil {
+	if objectPath, found := r.looseObjectPath(id); found {
 		return r.readLooseObjectData(objectPath)
 	}
 
-	for _, idx := range r.packIndices {
-		if offset, found := idx.FindObject(id); found {
-			file, err := os.Open(idx.PackFile())
-			if err != nil {
-				continue
-			}
-			defer file.Close()
-
-			if _, err := file.Seek(offset, 0); err != nil {
-				continue
-			}
-			return r.readPackObject(file)
-		}
+	if packPath, offset, found := r.findPackedObject(id); found {
+		return r.readPackObject(packPath, offset)
 	}
 
 	return nil, 0, fmt.Errorf("object not found: %s", id)
@@ -155,7 +205,10 @@ func (r *Repository) readLooseObjectData(objectPath string) ([]byte, byte, error
 
 // readLooseObject reads an object from loose object storage.
 func (r *Repository) readLooseObject(id Hash) (header string, content []byte, err error) {
-	objectPath := filepath.Join(r.gitDir, "objects", string(id)[:2], string(id)[2:])
+	objectPath, found := r.looseObjectPath(id)
+	if !found {
+		return "", nil, fmt.Errorf("object not found: %s", id)
+	}
 
 	file, err := os.Open(objectPath)
 	if err != nil {
@@ -179,17 +232,7 @@ func (r *Repository) readLooseObject(id Hash) (header string, content []byte, er
 
 // readPackedObject reads an object from a pack file at the given offset.
 func (r *Repository) readPackedObject(packPath string, offset int64, id Hash) (Object, error) {
-	file, err := os.Open(packPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open pack file: %w", err)
-	}
-	defer file.Close()
-
-	if _, err := file.Seek(offset, 0); err != nil {
-		return nil, fmt.Errorf("failed to seek to offset %d: %w", offset, err)
-	}
-
-	objectData, objectType, err := r.readPackObject(file)
+	objectData, objectType, err := r.readPackObject(packPath, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read pack object: %w", err)
 	}
@@ -199,6 +242,8 @@ func (r *Repository) readPackedObject(packPath string, offset int64, id Hash) (O
 		return r.parseCommitBody(objectData, id)
 	case TagObject:
 		return r.parseTagBody(objectData, id)
+	case TreeObject:
+		return r.parseTreeBody(objectData, id)
 	default:
 		return nil, fmt.Errorf("unknown object type: %d", objectType)
 	}
@@ -298,9 +343,56 @@ func (r *Repository) parseTagBody(body []byte, id Hash) (*Tag, error) {
 	return tag, nil
 }
 
-// readCompressedData reads and decompresses zlib-compressed data at the current file position.
-func (r *Repository) readCompressedData(file *os.File) ([]byte, error) {
-	zr, err := zlib.NewReader(file)
+// parseTreeBody parses the body of a tree object into a Tree struct.
+// Each entry is encoded as "<octal mode> <name>\x00<raw hash>".
+func (r *Repository) parseTreeBody(body []byte, id Hash) (*Tree, error) {
+	tree := &Tree{ID: id}
+
+	for len(body) > 0 {
+		spaceIdx := bytes.IndexByte(body, ' ')
+		if spaceIdx == -1 {
+			return nil, fmt.Errorf("invalid tree entry: missing mode")
+		}
+		mode, err := strconv.ParseUint(string(body[:spaceIdx]), 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tree entry mode %q: %w", body[:spaceIdx], err)
+		}
+		body = body[spaceIdx+1:]
+
+		nullIdx := bytes.IndexByte(body, 0)
+		if nullIdx == -1 {
+			return nil, fmt.Errorf("invalid tree entry: missing name terminator")
+		}
+		name := string(body[:nullIdx])
+		body = body[nullIdx+1:]
+
+		hashSize := r.objectFormat.Size()
+		if len(body) < hashSize {
+			return nil, fmt.Errorf("invalid tree entry %q: truncated hash", name)
+		}
+		raw := body[:hashSize]
+		body = body[hashSize:]
+
+		entryID, err := NewHashFromBytes(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tree entry %q: %w", name, err)
+		}
+
+		fileMode := FileMode(mode)
+		tree.Entries = append(tree.Entries, TreeEntry{
+			ID:   entryID,
+			Name: name,
+			Mode: fileMode,
+			Kind: fileMode.Kind(),
+		})
+	}
+
+	return tree, nil
+}
+
+// readCompressedData reads and decompresses zlib-compressed data at the current reader position.
+func (r *Repository) readCompressedData(reader io.Reader) ([]byte, error) {
+	zr, err := zlib.NewReader(reader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create zlib reader: %w", err)
 	}
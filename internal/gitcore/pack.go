@@ -1,23 +1,36 @@
 package gitcore
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 )
 
-// loadPackIndices scans the objects/pack directory and loads all pack index files.
+// loadPackIndices scans the pack directory of every object directory, including alternates, and
+// loads each one's multi-pack-index, if any, and the index files of all packs it does not cover.
 // This should be done before we begin loading objects, as some objects may be stored here.
 func (r *Repository) loadPackIndices() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	packDir := filepath.Join(r.gitDir, "objects", "pack")
+	for _, objectDir := range r.objectDirs {
+		if err := r.loadPackDir(filepath.Join(objectDir, "pack")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPackDir loads the multi-pack-index and uncovered pack indices of a single pack directory.
+func (r *Repository) loadPackDir(packDir string) error {
 	if _, err := os.Stat(packDir); os.IsNotExist(err) {
 		// No packs, this is ok.
 		return nil
@@ -25,6 +38,15 @@ func (r *Repository) loadPackIndices() error {
 		return err
 	}
 
+	midx, err := r.loadMultiPackIndex(packDir)
+	if err != nil {
+		// Log error and fall back to the individual pack indices.
+		log.Printf("failed to load multi-pack-index: %v", err)
+	}
+	if midx != nil {
+		r.multiPackIndices = append(r.multiPackIndices, midx)
+	}
+
 	entries, err := os.ReadDir(packDir)
 	if err != nil {
 		return fmt.Errorf("failed to read pack directory: %w", err)
@@ -37,6 +59,9 @@ func (r *Repository) loadPackIndices() error {
 		if !strings.HasSuffix(entry.Name(), ".idx") {
 			continue
 		}
+		if midx != nil && midx.covers(entry.Name()) {
+			continue
+		}
 
 		idxPath := filepath.Join(packDir, entry.Name())
 		idx, err := r.loadPackIndex(idxPath)
@@ -52,163 +77,128 @@ func (r *Repository) loadPackIndices() error {
 	return nil
 }
 
-// loadPackIndex loads a single pack index file, detecting its version internally.
+// findPackedObject locates an object in pack storage, consulting the multi-pack-indices before
+// the indices of packs they do not cover.
+// Returns the pack path, offset and true if found, otherwise returns false.
+func (r *Repository) findPackedObject(id Hash) (string, int64, bool) {
+	for _, midx := range r.multiPackIndices {
+		if packPath, offset, found := midx.FindObject(id); found {
+			return packPath, offset, true
+		}
+	}
+	for _, idx := range r.packIndices {
+		if offset, found := idx.FindObject(id); found {
+			return idx.PackFile(), offset, true
+		}
+	}
+	return "", 0, false
+}
+
+// loadPackIndex memory-maps a single pack index file, detecting its version internally.
+// The mapping is released by Repository.Close, or once the returned index is garbage collected.
 // See: https://git-scm.com/docs/pack-format#_original_version_1_pack_idx_files_have_the_following_format
 func (r *Repository) loadPackIndex(idxPath string) (*PackIndex, error) {
-	file, err := os.Open(idxPath)
+	data, err := mapFile(idxPath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-
-	var header [4]byte
-	if _, err := io.ReadFull(file, header[:]); err != nil {
-		return nil, fmt.Errorf("failed to read index header: %w", err)
-	}
-
-	// Version 2 pack-*.idx files begin with a magic number \377toc, which is an
-	// unreasonable first four bytes for version 1 files.
-	if header[0] == 0xFF && header[1] == 0x74 && header[2] == 0x4F && header[3] == 0x63 {
-		return r.loadPackIndexV2(file, idxPath)
-	}
-	// Need to reset to beginning of file for version 1.
-	if _, err := file.Seek(0, 0); err != nil {
-		return nil, fmt.Errorf("failed to seek to beginning: %w", err)
-	}
-	return r.loadPackIndexV1(file, idxPath)
-}
 
-// loadPackIndexV1 loads a version 1 pack index file.
-// See: https://git-scm.com/docs/pack-format#_original_version_1_pack_idx_files_have_the_following_format
-func (r *Repository) loadPackIndexV1(file *os.File, idxPath string) (*PackIndex, error) {
 	idx := &PackIndex{
 		path:     idxPath,
 		packPath: strings.Replace(idxPath, ".idx", ".pack", 1),
-		version:  1,
-		offsets:  make(map[Hash]int64),
+		hashSize: r.objectFormat.Size(),
 	}
 
-	for i := 0; i < 256; i++ {
-		if err := binary.Read(file, binary.BigEndian, &idx.fanout[i]); err != nil {
-			return nil, fmt.Errorf("failed to read fanout[%d]: %w", i, err)
+	// Version 2 and later pack-*.idx files begin with a magic number \377toc, which is an
+	// unreasonable first four bytes for version 1 files.
+	if len(data) >= 8 && bytes.Equal(data[:4], []byte{0xFF, 0x74, 0x4F, 0x63}) {
+		idx.version = binary.BigEndian.Uint32(data[4:])
+		switch idx.version {
+		case 2:
+			err = idx.parseV2(data)
+		default:
+			err = fmt.Errorf("unsupported pack index version %d", idx.version)
 		}
+	} else {
+		idx.version = 1
+		err = idx.parseV1(data)
 	}
-	idx.numObjects = idx.fanout[255]
-
-	for i := uint32(0); i < idx.numObjects; i++ {
-		var offset uint32
-		if err := binary.Read(file, binary.BigEndian, &offset); err != nil {
-			return nil, fmt.Errorf("failed to read offset %d: %w", i, err)
-		}
-
-		var name [20]byte
-		if _, err := io.ReadFull(file, name[:]); err != nil {
-			return nil, fmt.Errorf("failed to read object name %d: %w", i, err)
-		}
-
-		id, err := NewHashFromBytes(name)
-		if err != nil {
-			return nil, err
-		}
-		idx.offsets[id] = int64(offset)
+	if err != nil {
+		unmapFile(data)
+		return nil, err
 	}
 
+	idx.data = data
+	idx.cleanup = runtime.AddCleanup(idx, func(data []byte) {
+		if err := unmapFile(data); err != nil {
+			log.Printf("failed to unmap pack index: %v", err)
+		}
+	}, data)
 	return idx, nil
 }
 
-// loadPackIndexV2 loads a version 2 pack index file.
-// See: https://git-scm.com/docs/pack-format#_version_2_pack_idx_files_support_packs_larger_than_4_gib_and
-func (r *Repository) loadPackIndexV2(file *os.File, idxPath string) (*PackIndex, error) {
-	idx := &PackIndex{
-		path:     idxPath,
-		packPath: strings.Replace(idxPath, ".idx", ".pack", 1),
-		version:  2,
-		offsets:  make(map[Hash]int64),
-	}
-
-	var version uint32
-	if err := binary.Read(file, binary.BigEndian, &version); err != nil {
-		return nil, fmt.Errorf("failed to read version: %w", err)
-	}
-	if version != 2 {
-		return nil, fmt.Errorf("expected version 2, got %d", version)
-	}
-
-	for i := 0; i < 256; i++ {
-		if err := binary.Read(file, binary.BigEndian, &idx.fanout[i]); err != nil {
-			return nil, fmt.Errorf("failed to read fanout[%d]: %w", i, err)
-		}
-	}
-	idx.numObjects = idx.fanout[255]
-
-	objectNames := make([][20]byte, idx.numObjects)
-	for i := uint32(0); i < idx.numObjects; i++ {
-		if _, err := io.ReadFull(file, objectNames[i][:]); err != nil {
-			return nil, fmt.Errorf("failed to read object name %d: %w", i, err)
-		}
-	}
+// close unmaps the index file. The index must not be used afterwards.
+func (p *PackIndex) close() error {
+	p.cleanup.Stop()
+	data := p.data
+	p.data, p.fanout, p.names, p.offsets, p.largeOffsets = nil, nil, nil, nil, nil
+	p.numObjects = 0
+	return unmapFile(data)
+}
 
-	if _, err := file.Seek(int64(idx.numObjects*4), io.SeekCurrent); err != nil {
-		return nil, fmt.Errorf("failed to skip CRCs: %w", err)
+// parseV1 sets up lookups into a version 1 pack index file, whose entries interleave each
+// 4-byte offset with its object name.
+// See: https://git-scm.com/docs/pack-format#_original_version_1_pack_idx_files_have_the_following_format
+func (p *PackIndex) parseV1(data []byte) error {
+	if len(data) < 256*4 {
+		return fmt.Errorf("truncated fanout table")
 	}
+	p.fanout = data[:256*4]
+	p.numObjects = binary.BigEndian.Uint32(p.fanout[255*4:])
 
-	offsets := make([]uint32, idx.numObjects)
-	for i := uint32(0); i < idx.numObjects; i++ {
-		if err := binary.Read(file, binary.BigEndian, &offsets[i]); err != nil {
-			return nil, fmt.Errorf("failed to read offset %d: %w", i, err)
-		}
+	entrySize := 4 + p.hashSize
+	entries := data[256*4:]
+	if len(entries) < int(p.numObjects)*entrySize {
+		return fmt.Errorf("truncated object entries")
 	}
+	p.offsets, p.offsetStride = entries, entrySize
+	p.names, p.nameStride = entries[4:], entrySize
+	return nil
+}
 
-	var largeOffsets []uint64
-	for _, offset := range offsets {
-		if offset&0x80000000 != 0 {
-			if len(largeOffsets) == 0 {
-				for {
-					var largeOffset uint64
-					err := binary.Read(file, binary.BigEndian, &largeOffset)
-					if err == io.EOF {
-						break
-					}
-					if err != nil {
-						return nil, fmt.Errorf("failed to read large offset: %w", err)
-					}
-					largeOffsets = append(largeOffsets, largeOffset)
-				}
-			}
-		}
+// parseV2 sets up lookups into a version 2 pack index file.
+// See: https://git-scm.com/docs/pack-format#_version_2_pack_idx_files_support_packs_larger_than_4_gib_and
+func (p *PackIndex) parseV2(data []byte) error {
+	if len(data) < 8+256*4 {
+		return fmt.Errorf("truncated fanout table")
 	}
+	p.fanout = data[8 : 8+256*4]
+	p.numObjects = binary.BigEndian.Uint32(p.fanout[255*4:])
 
-	for i := uint32(0); i < idx.numObjects; i++ {
-		hash, err := NewHashFromBytes(objectNames[i])
-		if err != nil {
-			return nil, err
-		}
-
-		offset := offsets[i]
-		if offset&0x80000000 != 0 {
-			largeOffsetIdx := offset & 0x7Fffffff
-			if largeOffsetIdx >= uint32(len(largeOffsets)) {
-				continue
-			}
-			idx.offsets[hash] = int64(largeOffsets[largeOffsetIdx])
-		} else {
-			idx.offsets[hash] = int64(offset)
-		}
+	n := int(p.numObjects)
+	namesStart := 8 + 256*4
+	offsetsStart := namesStart + n*p.hashSize + n*4 // Skip the CRC table.
+	largeStart := offsetsStart + n*4
+	if largeStart > len(data) {
+		return fmt.Errorf("truncated object tables")
 	}
 
-	return idx, nil
+	p.names, p.nameStride = data[namesStart:], p.hashSize
+	p.offsets, p.offsetStride = data[offsetsStart:largeStart], 4
+	p.largeOffsets = data[largeStart:]
+	return nil
 }
 
-// readPackObject reads an object from a pack file at the current position.
+// readPackObject reads an object from a pack file at the given offset.
 // Returns the decompressed object data and its type.
 // See: https://git-scm.com/docs/pack-format#_pack_pack_files_have_the_following_format
-func (r *Repository) readPackObject(file *os.File) (data []byte, objectType byte, err error) {
-	objStart, err := file.Seek(0, io.SeekCurrent)
+func (r *Repository) readPackObject(packPath string, offset int64) (data []byte, objectType byte, err error) {
+	reader, err := r.openPackReader(packPath, offset)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	objType, size, err := r.readPackObjectHeader(file)
+	objType, size, err := r.readPackObjectHeader(reader)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -216,23 +206,48 @@ func (r *Repository) readPackObject(file *os.File) (data []byte, objectType byte
 	// See: https://git-scm.com/docs/pack-format#_object_types
 	switch objType {
 	case 1, 2, 3, 4:
-		data, err := r.readCompressedObject(file, size)
+		data, err := r.readCompressedObject(reader, size)
 		return data, objType, err
 	case 6:
-		return r.readOffsetDelta(file, size, objStart)
+		return r.readOffsetDelta(reader, packPath, size, offset)
 	case 7:
-		return r.readRefDelta(file, size)
+		return r.readRefDelta(reader, size)
 	default:
 		return nil, 0, fmt.Errorf("unsupported object type: %d", objType)
 	}
 }
 
+// openPackReader returns a buffered reader positioned at an offset in a pooled pack file.
+func (r *Repository) openPackReader(packPath string, offset int64) (*bufio.Reader, error) {
+	file, err := r.packFiles.open(packPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pack file: %w", err)
+	}
+	return bufio.NewReader(io.NewSectionReader(file, offset, math.MaxInt64-offset)), nil
+}
+
+// readDeltaBase reads the base object of a delta, serving it from the delta base cache when the
+// base was resolved recently.
+func (r *Repository) readDeltaBase(packPath string, offset int64) ([]byte, byte, error) {
+	key := deltaBaseKey{packPath: packPath, offset: offset}
+	if data, objectType, found := r.deltaBases.get(key); found {
+		return data, objectType, nil
+	}
+
+	data, objectType, err := r.readPackObject(packPath, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	r.deltaBases.add(key, data, objectType)
+	return data, objectType, nil
+}
+
 // readPackObjectHeader reads the variable-length header from a pack object.
 // Returns object type and the size of the uncompressed data.
 // See: https://git-scm.com/docs/pack-format#_pack_pack_files_have_the_following_format
-func (r *Repository) readPackObjectHeader(file *os.File) (objectType byte, size int64, err error) {
+func (r *Repository) readPackObjectHeader(reader io.Reader) (objectType byte, size int64, err error) {
 	var b [1]byte
-	if _, err := file.Read(b[:]); err != nil {
+	if _, err := io.ReadFull(reader, b[:]); err != nil {
 		return 0, 0, err
 	}
 
@@ -241,7 +256,7 @@ func (r *Repository) readPackObjectHeader(file *os.File) (objectType byte, size
 	shift := 4
 
 	for b[0]&0x80 != 0 {
-		if _, err := file.Read(b[:]); err != nil {
+		if _, err := io.ReadFull(reader, b[:]); err != nil {
 			return 0, 0, err
 		}
 		size |= int64(b[0]&0x7F) << shift
@@ -252,8 +267,8 @@ func (r *Repository) readPackObjectHeader(file *os.File) (objectType byte, size
 }
 
 // readCompressedObject reads and decompresses zlib-compressed object data and ensures its size matches the expected size.
-func (r *Repository) readCompressedObject(file *os.File, expectedSize int64) ([]byte, error) {
-	content, err := r.readCompressedData(file)
+func (r *Repository) readCompressedObject(reader io.Reader, expectedSize int64) ([]byte, error) {
+	content, err := r.readCompressedData(reader)
 	if err != nil {
 		return nil, fmt.Errorf("invalid compressed data: %w", err)
 	}
@@ -267,45 +282,30 @@ func (r *Repository) readCompressedObject(file *os.File, expectedSize int64) ([]
 // readOffsetDelta reads an offset delta object.
 // Returns the resulting data after applying the delta and the type of data referred to.
 // See: https://git-scm.com/docs/pack-format#_deltified_representation
-func (r *Repository) readOffsetDelta(file *os.File, size, objStart int64) ([]byte, byte, error) {
+func (r *Repository) readOffsetDelta(reader io.Reader, packPath string, size, objStart int64) ([]byte, byte, error) {
 	var b [1]byte
 
-	if _, err := file.Read(b[:]); err != nil {
+	if _, err := io.ReadFull(reader, b[:]); err != nil {
 		return nil, 0, err
 	}
 	offset := int64(b[0] & 0x7F)
 	for b[0]&0x80 != 0 {
-		if _, err := file.Read(b[:]); err != nil {
+		if _, err := io.ReadFull(reader, b[:]); err != nil {
 			return nil, 0, err
 		}
 		offset = ((offset + 1) << 7) | int64(b[0]&0x7F)
 	}
 
-	beforeDelta, err := file.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return nil, 0, err
-	}
-	deltaData, err := r.readCompressedObject(file, size)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to read offset delta data at %d: %w", beforeDelta, err)
-	}
-
-	afterDelta, err := file.Seek(0, io.SeekCurrent)
+	deltaData, err := r.readCompressedObject(reader, size)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, fmt.Errorf("failed to read offset delta data at %d: %w", objStart, err)
 	}
 
 	basePos := objStart - offset
-	if _, err := file.Seek(basePos, 0); err != nil {
-		return nil, 0, fmt.Errorf("failed to seek to base object at %d: %w", basePos, err)
-	}
-	baseData, baseType, err := r.readPackObject(file)
+	baseData, baseType, err := r.readDeltaBase(packPath, basePos)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to read base object at %d (type %d): %w", basePos, baseType, err)
 	}
-	if _, err := file.Seek(afterDelta, 0); err != nil {
-		return nil, 0, err
-	}
 
 	result, err := r.applyDelta(baseData, deltaData)
 	if err != nil {
@@ -318,9 +318,9 @@ func (r *Repository) readOffsetDelta(file *os.File, size, objStart int64) ([]byt
 // readRefDelta reads a reference delta object.
 // Returns the resulting data after applying the delta and the type of data referred to.
 // See: https://git-scm.com/docs/pack-format#_deltified_representation
-func (r *Repository) readRefDelta(file *os.File, size int64) ([]byte, byte, error) {
-	var baseHash [20]byte
-	if _, err := io.ReadFull(file, baseHash[:]); err != nil {
+func (r *Repository) readRefDelta(reader io.Reader, size int64) ([]byte, byte, error) {
+	baseHash := make([]byte, r.objectFormat.Size())
+	if _, err := io.ReadFull(reader, baseHash); err != nil {
 		return nil, 0, fmt.Errorf("failed to read base hash: %w", err)
 	}
 	baseHashStr, err := NewHashFromBytes(baseHash)
@@ -328,16 +328,18 @@ func (r *Repository) readRefDelta(file *os.File, size int64) ([]byte, byte, erro
 		return nil, 0, fmt.Errorf("invalid hash: %w", err)
 	}
 
-	beforeDelta, err := file.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return nil, 0, err
-	}
-	deltaData, err := r.readCompressedObject(file, size)
+	deltaData, err := r.readCompressedObject(reader, size)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to read ref delta data at %d: %w", beforeDelta, err)
+		return nil, 0, fmt.Errorf("failed to read ref delta data: %w", err)
 	}
 
-	baseData, baseType, err := r.readObjectData(baseHashStr)
+	var baseData []byte
+	var baseType byte
+	if packPath, offset, found := r.findPackedObject(baseHashStr); found {
+		baseData, baseType, err = r.readDeltaBase(packPath, offset)
+	} else {
+		baseData, baseType, err = r.readObjectData(baseHashStr)
+	}
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to read base object %s: %w", baseHashStr.Short(), err)
 	}
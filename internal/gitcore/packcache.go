@@ -0,0 +1,170 @@
+package gitcore
+
+import (
+	"container/list"
+	"errors"
+	"os"
+	"sync"
+)
+
+// deltaBaseCacheLimit bounds the total size of cached delta bases, matching git's default
+// core.deltaBaseCacheLimit.
+const deltaBaseCacheLimit = 96 << 20
+
+// PackCacheStats reports how pack reads are being served.
+type PackCacheStats struct {
+	DeltaBaseHits      uint64 `json:"deltaBaseHits"`
+	DeltaBaseMisses    uint64 `json:"deltaBaseMisses"`
+	DeltaBaseEvictions uint64 `json:"deltaBaseEvictions"`
+	DeltaBaseEntries   int    `json:"deltaBaseEntries"`
+	DeltaBaseBytes     int64  `json:"deltaBaseBytes"`
+	DeltaBaseLimit     int64  `json:"deltaBaseLimit"`
+	OpenPackFiles      int    `json:"openPackFiles"`
+}
+
+// PackCacheStats returns a snapshot of the delta base cache and pack file pool counters.
+func (r *Repository) PackCacheStats() PackCacheStats {
+	stats := r.deltaBases.stats()
+	stats.OpenPackFiles = r.packFiles.len()
+	return stats
+}
+
+// deltaBaseKey identifies an object by its location in a pack.
+type deltaBaseKey struct {
+	packPath string
+	offset   int64
+}
+
+// deltaBaseEntry is a resolved delta base held by the cache.
+type deltaBaseEntry struct {
+	key        deltaBaseKey
+	data       []byte
+	objectType byte
+}
+
+// deltaBaseCache is a least-recently-used cache of resolved delta base objects, bounded by their
+// total size. Without it, every object in a delta chain re-inflates the whole chain below it.
+type deltaBaseCache struct {
+	mu      sync.Mutex
+	limit   int64
+	size    int64
+	order   *list.List // Front is most recently used.
+	entries map[deltaBaseKey]*list.Element
+
+	hits, misses, evictions uint64
+}
+
+// newDeltaBaseCache creates an empty cache holding at most limit bytes of object data.
+func newDeltaBaseCache(limit int64) *deltaBaseCache {
+	return &deltaBaseCache{
+		limit:   limit,
+		order:   list.New(),
+		entries: make(map[deltaBaseKey]*list.Element),
+	}
+}
+
+// get returns the cached object at a pack location. Callers must not modify the returned data.
+func (c *deltaBaseCache) get(key deltaBaseKey) ([]byte, byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, found := c.entries[key]
+	if !found {
+		c.misses++
+		return nil, 0, false
+	}
+	c.hits++
+	c.order.MoveToFront(elem)
+	entry := elem.Value.(*deltaBaseEntry)
+	return entry.data, entry.objectType, true
+}
+
+// add stores an object, evicting the least recently used entries to stay within the limit.
+// Objects larger than the whole cache are not stored.
+func (c *deltaBaseCache) add(key deltaBaseKey, data []byte, objectType byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if int64(len(data)) > c.limit {
+		return
+	}
+	if elem, found := c.entries[key]; found {
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&deltaBaseEntry{key: key, data: data, objectType: objectType})
+	c.size += int64(len(data))
+	for c.size > c.limit {
+		oldest := c.order.Back()
+		entry := c.order.Remove(oldest).(*deltaBaseEntry)
+		delete(c.entries, entry.key)
+		c.size -= int64(len(entry.data))
+		c.evictions++
+	}
+}
+
+// stats returns the cache's counters.
+func (c *deltaBaseCache) stats() PackCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return PackCacheStats{
+		DeltaBaseHits:      c.hits,
+		DeltaBaseMisses:    c.misses,
+		DeltaBaseEvictions: c.evictions,
+		DeltaBaseEntries:   len(c.entries),
+		DeltaBaseBytes:     c.size,
+		DeltaBaseLimit:     c.limit,
+	}
+}
+
+// packFilePool keeps pack files open for the lifetime of the repository.
+// Files are only read with ReadAt, so one handle can serve concurrent readers.
+type packFilePool struct {
+	mu    sync.Mutex
+	files map[string]*os.File
+}
+
+// newPackFilePool creates an empty pool.
+func newPackFilePool() *packFilePool {
+	return &packFilePool{files: make(map[string]*os.File)}
+}
+
+// open returns the pooled handle for a pack file, opening it on first use.
+func (p *packFilePool) open(packPath string) (*os.File, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if file, found := p.files[packPath]; found {
+		return file, nil
+	}
+	file, err := os.Open(packPath)
+	if err != nil {
+		return nil, err
+	}
+	p.files[packPath] = file
+	return file, nil
+}
+
+// close closes every pooled pack file.
+func (p *packFilePool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+	for packPath, file := range p.files {
+		if err := file.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(p.files, packPath)
+	}
+	return errors.Join(errs...)
+}
+
+// len returns the number of open pack files.
+func (p *packFilePool) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.files)
+}
@@ -0,0 +1,147 @@
+package gitcore
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReflogEntry records one update of a ref: where it pointed before and after, who moved it and
+// when, and why (e.g. "commit: Fix typo" or "checkout: moving from main to topic").
+// Old is the zero hash when the update created the ref.
+type ReflogEntry struct {
+	Old       Hash      `json:"old"`
+	New       Hash      `json:"new"`
+	Committer Signature `json:"committer"`
+	Message   string    `json:"message"`
+}
+
+// loadReflogs parses logs/HEAD of the git directory and every reflog under logs/refs of the
+// common directory, so that a linked worktree sees its own HEAD's reflog and the shared ones.
+// See: https://git-scm.com/docs/gitrepository-layout#Documentation/gitrepository-layout.txt-logs
+func (r *Repository) loadReflogs() error {
+	if r.refStorage == RefStorageReftable {
+		// Reftable stores reflogs alongside refs, so loadReftable has already read them.
+		return nil
+	}
+	r.reflogs = make(map[string][]ReflogEntry)
+
+	headLog := filepath.Join(r.gitDir, "logs", "HEAD")
+	if entries, err := r.readReflog(headLog); err == nil {
+		r.reflogs["HEAD"] = entries
+	} else if !os.IsNotExist(err) {
+		// Log the error but continue with other potentially valid reflogs.
+		log.Printf("error reading reflog of HEAD: %v", err)
+	}
+
+	logsDir := filepath.Join(r.commonDir, "logs")
+	refLogsDir := filepath.Join(logsDir, "refs")
+	if _, err := os.Stat(refLogsDir); os.IsNotExist(err) {
+		// Reflogs are disabled (e.g. in bare repositories by default), this is ok.
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return filepath.Walk(refLogsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(logsDir, path)
+		if err != nil {
+			return err
+		}
+		refName := filepath.ToSlash(relPath)
+
+		entries, err := r.readReflog(path)
+		if err != nil {
+			// Log the error but continue with other potentially valid reflogs.
+			log.Printf("error reading reflog of %s: %v", refName, err)
+			return nil
+		}
+		r.reflogs[refName] = entries
+		return nil
+	})
+}
+
+// readReflog parses a single reflog file, whose lines have the form
+// "<old> <new> <name> <<email>> <timestamp> <timezone>\t<message>".
+// Like git, it skips lines it cannot parse, e.g. one left half-written by a crash, rather than
+// discarding the entries around them.
+func (r *Repository) readReflog(path string) ([]ReflogEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []ReflogEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		entry, err := parseReflogEntry(line)
+		if err != nil {
+			log.Printf("skipping line %d of reflog %s: %v", lineNumber, path, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// parseReflogEntry parses one line of a reflog.
+func parseReflogEntry(line string) (ReflogEntry, error) {
+	header, message, _ := strings.Cut(line, "\t")
+
+	fields := strings.SplitN(header, " ", 3)
+	if len(fields) != 3 {
+		return ReflogEntry{}, fmt.Errorf("invalid reflog entry: %q", line)
+	}
+	oldID, err := NewHash(fields[0])
+	if err != nil {
+		return ReflogEntry{}, fmt.Errorf("invalid old hash in reflog entry: %w", err)
+	}
+	newID, err := NewHash(fields[1])
+	if err != nil {
+		return ReflogEntry{}, fmt.Errorf("invalid new hash in reflog entry: %w", err)
+	}
+	committer, err := NewSignature(fields[2])
+	if err != nil {
+		return ReflogEntry{}, err
+	}
+
+	return ReflogEntry{
+		Old:       oldID,
+		New:       newID,
+		Committer: committer,
+		Message:   message,
+	}, nil
+}
+
+// Reflog returns the recorded updates of a ref, oldest first, or nil if it has no reflog.
+// ref is HEAD or a full ref name such as refs/heads/main.
+func (r *Repository) Reflog(ref string) []ReflogEntry {
+	return r.reflogs[ref]
+}
+
+// ReflogRefs returns the names of all refs that have a reflog.
+func (r *Repository) ReflogRefs() []string {
+	result := make([]string, 0, len(r.reflogs))
+	for refName := range r.reflogs {
+		result = append(result, refName)
+	}
+	return result
+}
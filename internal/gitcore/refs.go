@@ -6,34 +6,292 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 )
 
-// loadRefs loads all Git references (branches, tags) into the refs map.
+// RefKind classifies a ref by the namespace it lives in.
+type RefKind string
+
+const (
+	RefBranch       RefKind = "branch"
+	RefRemoteBranch RefKind = "remote"
+	RefTag          RefKind = "tag"
+	// RefPullRequest covers GitHub's refs/pull/* and GitLab's refs/merge-requests/*.
+	RefPullRequest RefKind = "pull"
+	// RefChange covers Gerrit's refs/changes/*.
+	RefChange RefKind = "change"
+	// RefOther covers any other namespace, such as ones created by custom tooling.
+	RefOther RefKind = "other"
+)
+
+// Ref is a named reference to an object.
+type Ref struct {
+	Name string  `json:"name"`
+	Kind RefKind `json:"kind"`
+	Hash Hash    `json:"hash"`
+}
+
+// RefFilter selects refs by kind and by name. Patterns are matched against full ref names, either
+// as globs, or literally, in which case a pattern matches the ref itself and every ref below it
+// (e.g. "refs/pull" matches "refs/pull/1/head"), like the patterns of git for-each-ref. In a glob,
+// "*" matches any run of characters including "/", "?" matches one character, and a bracket
+// expression such as "[0-9]" or "[!a-z]" matches one character of a class, as in path.Match; a
+// "[" without a closing "]" is literal. Empty fields select everything.
+//
+// Filters built with Compile match refs without translating their patterns again for each ref.
+type RefFilter struct {
+	Kinds   []RefKind `json:"kinds,omitempty"`
+	Include []string  `json:"include,omitempty"`
+	Exclude []string  `json:"exclude,omitempty"`
+
+	compiled         bool
+	include, exclude []refPattern
+}
+
+// refPattern is a compiled include or exclude pattern: a glob, or a literal ref name prefix.
+type refPattern struct {
+	prefix string
+	glob   *regexp.Regexp
+}
+
+// refKind classifies a full ref name.
+func refKind(name string) RefKind {
+	switch {
+	case strings.HasPrefix(name, "refs/heads/"):
+		return RefBranch
+	case strings.HasPrefix(name, remoteRefPrefix):
+		return RefRemoteBranch
+	case strings.HasPrefix(name, tagRefPrefix):
+		return RefTag
+	case strings.HasPrefix(name, "refs/pull/"), strings.HasPrefix(name, "refs/merge-requests/"):
+		return RefPullRequest
+	case strings.HasPrefix(name, "refs/changes/"):
+		return RefChange
+	default:
+		return RefOther
+	}
+}
+
+// Compile returns a copy of the filter with its patterns compiled. It fails on a pattern with an
+// invalid bracket expression, such as the reversed range "[z-a]".
+func (f RefFilter) Compile() (RefFilter, error) {
+	if f.compiled {
+		return f, nil
+	}
+	var err error
+	if f.include, err = compileRefPatterns(f.Include); err != nil {
+		return f, err
+	}
+	if f.exclude, err = compileRefPatterns(f.Exclude); err != nil {
+		return f, err
+	}
+	f.compiled = true
+	return f, nil
+}
+
+// Matches reports whether a ref passes the filter: it must be of one of the listed kinds, match
+// at least one include pattern, and match no exclude pattern. A filter that was not compiled
+// compiles its patterns on every call, skipping invalid ones, which then match nothing.
+func (f RefFilter) Matches(name string) bool {
+	if len(f.Kinds) > 0 {
+		kind, found := refKind(name), false
+		for _, k := range f.Kinds {
+			found = found || k == kind
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.compiled {
+		f.include, _ = compileRefPatterns(f.Include)
+		f.exclude, _ = compileRefPatterns(f.Exclude)
+	}
+	if len(f.Include) > 0 && !matchAnyRefPattern(f.include, name) {
+		return false
+	}
+	return !matchAnyRefPattern(f.exclude, name)
+}
+
+// matchAnyRefPattern reports whether name matches any of the compiled patterns.
+func matchAnyRefPattern(patterns []refPattern, name string) bool {
+	for _, pattern := range patterns {
+		if pattern.glob != nil {
+			if pattern.glob.MatchString(name) {
+				return true
+			}
+		} else if name == pattern.prefix || strings.HasPrefix(name, pattern.prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// compileRefPatterns compiles patterns as described on RefFilter. It returns the valid ones along
+// with the error of the first invalid one.
+func compileRefPatterns(patterns []string) ([]refPattern, error) {
+	var compiled []refPattern
+	var firstErr error
+	for _, pattern := range patterns {
+		if !strings.ContainsAny(pattern, "*?[") {
+			compiled = append(compiled, refPattern{prefix: strings.TrimSuffix(pattern, "/")})
+			continue
+		}
+
+		var expr strings.Builder
+		expr.WriteString("^")
+		for i := 0; i < len(pattern); i++ {
+			switch c := pattern[i]; c {
+			case '*':
+				expr.WriteString(".*")
+			case '?':
+				expr.WriteString(".")
+			case '[':
+				class, n := globBracketExpression(pattern[i:])
+				if n == 0 {
+					expr.WriteString(regexp.QuoteMeta("["))
+					continue
+				}
+				expr.WriteString(class)
+				i += n - 1
+			default:
+				expr.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		}
+		expr.WriteString("$")
+
+		glob, err := regexp.Compile(expr.String())
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("invalid ref pattern %q: %w", pattern, err)
+			}
+			continue
+		}
+		compiled = append(compiled, refPattern{glob: glob})
+	}
+	return compiled, firstErr
+}
+
+// globBracketExpression translates the bracket expression at the start of s, e.g. "[a-z]" or
+// "[!0-9]", into a regular expression class. It returns the class and the length of the
+// expression in s, which is 0 if the bracket is never closed. As in path.Match, a "]" right after
+// the opening bracket or its negation is a member of the class rather than its end.
+func globBracketExpression(s string) (string, int) {
+	i := 1
+	negated := i < len(s) && (s[i] == '!' || s[i] == '^')
+	if negated {
+		i++
+	}
+	start := i
+	if i < len(s) && s[i] == ']' {
+		i++
+	}
+	for i < len(s) && s[i] != ']' {
+		i++
+	}
+	if i >= len(s) {
+		return "", 0
+	}
+
+	var class strings.Builder
+	class.WriteString("[")
+	if negated {
+		class.WriteString("^")
+	}
+	for _, c := range s[start:i] {
+		if strings.ContainsRune(`\[]^`, c) {
+			class.WriteByte('\\')
+		}
+		class.WriteRune(c)
+	}
+	class.WriteString("]")
+	return class.String(), i + 1
+}
+
+// Refs returns the loaded refs that pass the filter, sorted by name. Refs with a dedicated
+// representation (HEAD, stashes, notes and replace refs) are not included.
+func (r *Repository) Refs(filter RefFilter) []Ref {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if compiled, err := filter.Compile(); err == nil {
+		filter = compiled
+	}
+	refs := make([]Ref, 0, len(r.refs))
+	for name, hash := range r.refs {
+		if filter.Matches(name) {
+			refs = append(refs, Ref{Name: name, Kind: refKind(name), Hash: hash})
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Name < refs[j].Name
+	})
+	return refs
+}
+
+// refsByName indexes refs by their full name.
+func refsByName(refs []Ref) map[string]Ref {
+	result := make(map[string]Ref, len(refs))
+	for _, ref := range refs {
+		result[ref.Name] = ref
+	}
+	return result
+}
+
+// loadRefs loads every ref under refs/ into the refs map, then sets aside those with a dedicated
+// representation and drops those rejected by Options.Refs. Refs that are dropped are not
+// traversed, so history reachable only from them is not loaded.
 func (r *Repository) loadRefs() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if err := r.loadLooseRefs("heads"); err != nil {
-		return fmt.Errorf("failed to load loose branches: %w", err)
-	}
-	if err := r.loadLooseRefs("tags"); err != nil {
-		return fmt.Errorf("failed to load loose tags: %w", err)
-	}
-	if err := r.loadPackedRefs(); err != nil {
-		return fmt.Errorf("failed to load packed refs: %w", err)
+	if r.refStorage == RefStorageReftable {
+		if err := r.loadReftable(); err != nil {
+			return fmt.Errorf("failed to load reftable: %w", err)
+		}
+	} else {
+		// Packed refs are loaded first so that loose refs, which are always more recent, override them.
+		if err := r.loadPackedRefs(); err != nil {
+			return fmt.Errorf("failed to load packed refs: %w", err)
+		}
+		if err := r.loadLooseRefs(r.commonDir, ""); err != nil {
+			return fmt.Errorf("failed to load loose refs: %w", err)
+		}
+		if r.gitDir != r.commonDir {
+			// A linked worktree keeps its own bisect and worktree refs, hiding the main worktree's.
+			for name := range r.refs {
+				if isPerWorktreeRef(name) {
+					delete(r.refs, name)
+				}
+			}
+			if err := r.loadLooseRefs(r.gitDir, ""); err != nil {
+				return fmt.Errorf("failed to load worktree refs: %w", err)
+			}
+		}
 	}
 	if err := r.loadHEAD(); err != nil {
 		return fmt.Errorf("failed to load head: %w", err)
 	}
+	r.loadReplaceRefs()
+	r.loadNotesRefs()
+	r.loadStashRef()
+	r.loadWorktrees()
+
+	for name := range r.refs {
+		if !r.options.Refs.Matches(name) {
+			delete(r.refs, name)
+		}
+	}
 
 	return nil
 }
 
-// loadLooseRefs recursively loads all refs in a directory.
-// prefix is like "heads" for branches, or "tags" for tags.
-func (r *Repository) loadLooseRefs(prefix string) error {
-	refsDir := filepath.Join(r.gitDir, "refs", prefix)
+// loadLooseRefs recursively loads all refs in the refs directory of dir, which is the common
+// directory or, for per-worktree refs, the git directory of a linked worktree.
+// prefix is like "heads" for branches, "remotes" for remote-tracking branches, or "" for all refs.
+func (r *Repository) loadLooseRefs(dir, prefix string) error {
+	refsDir := filepath.Join(dir, "refs", prefix)
 
 	if _, err := os.Stat(refsDir); os.IsNotExist(err) {
 		// No refs of this type yet (e.g., new repo with no tags), this is ok.
@@ -46,11 +304,11 @@ func (r *Repository) loadLooseRefs(prefix string) error {
 		if err != nil {
 			return err
 		}
-		if info.IsDir() {
+		if info.IsDir() || strings.HasSuffix(info.Name(), ".lock") {
 			return nil
 		}
 
-		relPath, err := filepath.Rel(r.gitDir, path)
+		relPath, err := filepath.Rel(dir, path)
 		if err != nil {
 			return err
 		}
@@ -69,8 +327,11 @@ func (r *Repository) loadLooseRefs(prefix string) error {
 }
 
 // loadPackedRefs reads the packed-refs file and loads all refs within.
+// A "^" line following a ref records the object that ref peels to when it names an annotated
+// tag; these are kept by tag object, so they stay valid even if a loose ref overrides the ref.
+// See: https://git-scm.com/docs/gitrepository-layout#Documentation/gitrepository-layout.txt-packed-refs
 func (r *Repository) loadPackedRefs() error {
-	packedRefsFile := filepath.Join(r.gitDir, "packed-refs")
+	packedRefsFile := filepath.Join(r.commonDir, "packed-refs")
 
 	file, err := os.Open(packedRefsFile)
 	if err != nil {
@@ -81,13 +342,27 @@ func (r *Repository) loadPackedRefs() error {
 	}
 	defer file.Close()
 
+	var previous Hash
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
 
+		if peel, found := strings.CutPrefix(line, "^"); found {
+			peeled, err := NewHash(peel)
+			if err != nil || previous == "" {
+				continue
+			}
+			if r.peeledTags == nil {
+				r.peeledTags = make(map[Hash]Hash)
+			}
+			r.peeledTags[previous] = peeled
+			continue
+		}
+
+		previous = ""
 		parts := strings.Fields(line)
 		if len(parts) != 2 {
 			continue
@@ -100,46 +375,40 @@ func (r *Repository) loadPackedRefs() error {
 
 		refName := parts[1]
 		r.refs[refName] = hash
+		previous = hash
 	}
 
 	return scanner.Err()
 }
 
-// loadHEAD reads and caches HEAD information.
+// loadHEAD reads and caches HEAD information from the git directory, which for a linked
+// worktree holds that worktree's own HEAD.
 func (r *Repository) loadHEAD() error {
-	headPath := filepath.Join(r.gitDir, "HEAD")
-	content, err := os.ReadFile(headPath)
+	ref, id, err := r.readWorktreeHead(r.gitDir)
 	if err != nil {
-		return fmt.Errorf("failed to read HEAD: %w", err)
+		return err
 	}
 
-	line := strings.TrimSpace(string(content))
-
-	if strings.HasPrefix(line, "ref: ") {
-		r.headRef = strings.TrimPrefix(line, "ref: ")
-		r.headDetached = false
-
-		if hash, exists := r.refs[r.headRef]; exists {
-			r.head = hash
-		} else {
-			r.head = "" // New repository with no commits, this is ok.
-		}
-	} else {
-		r.headDetached = true
-		r.headRef = ""
-
-		hash, err := NewHash(line)
-		if err != nil {
-			return fmt.Errorf("invalid HEAD: %w", err)
-		}
-		r.head = hash
+	r.headRef = ref
+	r.headDetached = ref == ""
+	r.head = id
+	if !r.headDetached {
+		r.head = r.refs[ref] // Empty for a new repository with no commits, this is ok.
 	}
-
 	return nil
 }
 
+// refPath returns the loose ref file of a ref, which lives in the git directory if the ref
+// belongs to a single worktree and in the common directory otherwise.
+func (r *Repository) refPath(name string) string {
+	if isPerWorktreeRef(name) {
+		return filepath.Join(r.gitDir, name)
+	}
+	return filepath.Join(r.commonDir, name)
+}
+
 // resolveRef reads a single ref file and returns its hash.
-// Handles both direct hashes and symbolic refs.
+// Handles both direct hashes and symbolic refs, whose targets may be packed.
 func (r *Repository) resolveRef(path string) (Hash, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
@@ -150,7 +419,12 @@ func (r *Repository) resolveRef(path string) (Hash, error) {
 
 	if strings.HasPrefix(line, "ref: ") {
 		targetRef := strings.TrimPrefix(line, "ref: ")
-		targetPath := filepath.Join(r.gitDir, targetRef)
+		targetPath := r.refPath(targetRef)
+		if _, err := os.Stat(targetPath); os.IsNotExist(err) {
+			if hash, found := r.refs[targetRef]; found {
+				return hash, nil
+			}
+		}
 		return r.resolveRef(targetPath)
 	}
 
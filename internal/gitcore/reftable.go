@@ -0,0 +1,533 @@
+package gitcore
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RefStorage identifies how a repository stores its refs.
+// See: https://git-scm.com/docs/git-config#Documentation/git-config.txt-extensionsrefStorage
+type RefStorage string
+
+const (
+	// RefStorageFiles stores refs as loose files under refs/ and in packed-refs.
+	RefStorageFiles RefStorage = "files"
+	// RefStorageReftable stores refs and reflogs in a stack of reftable files under reftable/.
+	RefStorageReftable RefStorage = "reftable"
+)
+
+// Reftable block types.
+// See: https://git-scm.com/docs/reftable#_file_format
+const (
+	reftableRefBlock   = 'r'
+	reftableLogBlock   = 'g'
+	reftableObjBlock   = 'o'
+	reftableIndexBlock = 'i'
+)
+
+// Reftable value types of ref and log records.
+const (
+	reftableRefDeletion = 0
+	reftableRefValue    = 1
+	reftableRefPeeled   = 2
+	reftableRefSymref   = 3
+
+	reftableLogDeletion = 0
+	reftableLogUpdate   = 1
+)
+
+// reftableMaxListAttempts bounds how often tables.list is re-read when a table it names has
+// already been removed by a concurrent compaction.
+const reftableMaxListAttempts = 3
+
+// reftableRef is a ref record. A deletion hides the ref in every older table of the stack.
+type reftableRef struct {
+	name    string
+	deleted bool
+	id      Hash
+	peeled  Hash
+	target  string
+}
+
+// reftableLog is a log record, one entry of a ref's reflog.
+type reftableLog struct {
+	name        string
+	updateIndex uint64
+	deleted     bool
+	entry       ReflogEntry
+}
+
+// reftable is the parsed content of a single table file.
+type reftable struct {
+	refs []reftableRef
+	logs []reftableLog
+}
+
+// loadReftable reads the shared reftable stack, newest table last, into the refs map and the
+// reflogs, and records the peeled values of annotated tags. HEAD is read per worktree by loadHEAD.
+// See: https://git-scm.com/docs/reftable
+func (r *Repository) loadReftable() error {
+	tables, err := readReftableStack(filepath.Join(r.commonDir, "reftable"), r.objectFormat)
+	if err != nil {
+		return err
+	}
+
+	refs := make(map[string]reftableRef)
+	for _, table := range tables {
+		for _, ref := range table.refs {
+			refs[ref.name] = ref
+		}
+	}
+
+	for name, ref := range refs {
+		if ref.deleted || name == "HEAD" {
+			continue
+		}
+		id, err := resolveReftableRef(refs, name)
+		if err != nil {
+			// Log the error but continue with other potentially valid refs, e.g. a dangling
+			// refs/remotes/origin/HEAD.
+			log.Printf("error resolving ref: %v", err)
+			continue
+		}
+		r.refs[name] = id
+		if ref.peeled != "" {
+			if r.peeledTags == nil {
+				r.peeledTags = make(map[Hash]Hash)
+			}
+			r.peeledTags[ref.id] = ref.peeled
+		}
+	}
+
+	r.reflogs = mergeReftableLogs(tables)
+	if r.gitDir != r.commonDir {
+		// A linked worktree logs its HEAD in its own stack.
+		delete(r.reflogs, "HEAD")
+		worktreeTables, err := readReftableStack(filepath.Join(r.gitDir, "reftable"), r.objectFormat)
+		if err != nil {
+			return err
+		}
+		if entries, found := mergeReftableLogs(worktreeTables)["HEAD"]; found {
+			r.reflogs["HEAD"] = entries
+		}
+	}
+
+	return nil
+}
+
+// mergeReftableLogs combines the log records of a stack into reflogs, oldest entry first. A
+// record in a newer table replaces the one with the same update index in an older table.
+func mergeReftableLogs(tables []*reftable) map[string][]ReflogEntry {
+	logs := make(map[string]map[uint64]reftableLog)
+	for _, table := range tables {
+		for _, entry := range table.logs {
+			if logs[entry.name] == nil {
+				logs[entry.name] = make(map[uint64]reftableLog)
+			}
+			logs[entry.name][entry.updateIndex] = entry
+		}
+	}
+
+	reflogs := make(map[string][]ReflogEntry)
+	for name, entries := range logs {
+		indices := make([]uint64, 0, len(entries))
+		for updateIndex, entry := range entries {
+			if !entry.deleted {
+				indices = append(indices, updateIndex)
+			}
+		}
+		sort.Slice(indices, func(i, j int) bool {
+			return indices[i] < indices[j]
+		})
+		for _, updateIndex := range indices {
+			reflogs[name] = append(reflogs[name], entries[updateIndex].entry)
+		}
+	}
+	return reflogs
+}
+
+// resolveReftableRef follows symbolic refs within the merged stack, like resolveRef does for
+// loose refs.
+func resolveReftableRef(refs map[string]reftableRef, name string) (Hash, error) {
+	for depth := 0; depth < 5; depth++ {
+		ref, found := refs[name]
+		if !found || ref.deleted {
+			return "", fmt.Errorf("dangling symbolic ref: %s", name)
+		}
+		if ref.target == "" {
+			return ref.id, nil
+		}
+		name = ref.target
+	}
+	return "", fmt.Errorf("symbolic ref nested too deeply: %s", name)
+}
+
+// readReftableStack reads every table named in the tables.list of a stack directory, oldest first. Tables are
+// replaced atomically by writers, so a missing table means the list changed underfoot and is
+// read again.
+func readReftableStack(dir string, format ObjectFormat) ([]*reftable, error) {
+	var err error
+	for attempt := 0; attempt < reftableMaxListAttempts; attempt++ {
+		var list []byte
+		if list, err = os.ReadFile(filepath.Join(dir, "tables.list")); err != nil {
+			return nil, err
+		}
+
+		tables := make([]*reftable, 0)
+		for _, name := range strings.Fields(string(list)) {
+			var table *reftable
+			if table, err = readReftable(filepath.Join(dir, name), format); err != nil {
+				break
+			}
+			tables = append(tables, table)
+		}
+		if err == nil {
+			return tables, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// readReftable parses a single table: its header, every ref and log block, and its footer.
+// Object and index blocks only speed up lookups, so they are skipped; the whole table is scanned.
+func readReftable(path string, format ObjectFormat) (*reftable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 24 || string(data[:4]) != "REFT" {
+		return nil, fmt.Errorf("invalid reftable %s: bad magic", path)
+	}
+
+	version := data[4]
+	headerSize, footerSize := 24, 68
+	switch version {
+	case 1:
+		if format != SHA1 {
+			return nil, fmt.Errorf("invalid reftable %s: version 1 requires sha1", path)
+		}
+	case 2:
+		headerSize, footerSize = 28, 72
+		if len(data) < headerSize {
+			return nil, fmt.Errorf("invalid reftable %s: truncated header", path)
+		}
+		hashID := string(data[24:28])
+		if (hashID == "sha1" && format != SHA1) || (hashID == "s256" && format != SHA256) {
+			return nil, fmt.Errorf("invalid reftable %s: hash %q does not match repository", path, hashID)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported reftable version %d in %s", version, path)
+	}
+
+	footerStart := len(data) - footerSize
+	if footerStart < headerSize || !bytes.Equal(data[footerStart:footerStart+headerSize], data[:headerSize]) {
+		return nil, fmt.Errorf("invalid reftable %s: bad footer", path)
+	}
+	checksum := binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(data[footerStart:len(data)-4]) != checksum {
+		return nil, fmt.Errorf("invalid reftable %s: footer checksum mismatch", path)
+	}
+
+	blockSize := int(data[5])<<16 | int(data[6])<<8 | int(data[7])
+
+	table := &reftable{}
+	if footerStart == headerSize {
+		// A table may hold no records at all, e.g. one written by a compaction that dropped them.
+		return table, nil
+	}
+	hashSize := format.Size()
+	for off := 0; off < footerStart; {
+		headerOff := 0
+		if off == 0 {
+			headerOff = headerSize
+		}
+		if off+headerOff+4 > footerStart {
+			return nil, fmt.Errorf("invalid reftable %s: truncated block at %d", path, off)
+		}
+
+		blockType := data[off+headerOff]
+		blockLen := int(data[off+headerOff+1])<<16 | int(data[off+headerOff+2])<<8 | int(data[off+headerOff+3])
+		var block []byte
+		var next int
+		switch blockType {
+		case reftableLogBlock:
+			// Log blocks are zlib-compressed after their header; blockLen is the inflated size.
+			block, next, err = inflateReftableBlock(data, off, headerOff+4, blockLen, footerStart)
+		case reftableRefBlock, reftableObjBlock, reftableIndexBlock:
+			if off+blockLen > footerStart || blockLen < headerOff+4 {
+				err = fmt.Errorf("block length %d out of range", blockLen)
+			} else {
+				block, next = data[off:off+blockLen], off+blockLen
+			}
+		default:
+			err = fmt.Errorf("unknown block type %q", blockType)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid reftable %s: block at %d: %w", path, off, err)
+		}
+
+		switch blockType {
+		case reftableRefBlock:
+			err = parseReftableBlock(block, headerOff, func(key []byte, valueType byte, value []byte) (int, error) {
+				ref, n, err := parseReftableRef(string(key), valueType, value, hashSize)
+				if err == nil {
+					table.refs = append(table.refs, ref)
+				}
+				return n, err
+			})
+		case reftableLogBlock:
+			err = parseReftableBlock(block, headerOff, func(key []byte, valueType byte, value []byte) (int, error) {
+				entry, n, err := parseReftableLog(key, valueType, value, hashSize)
+				if err == nil {
+					table.logs = append(table.logs, entry)
+				}
+				return n, err
+			})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid reftable %s: block at %d: %w", path, off, err)
+		}
+
+		// Aligned tables pad blocks with zeros up to the next multiple of the block size.
+		if next < footerStart && data[next] == 0 && blockSize > 0 {
+			next = (next + blockSize - 1) / blockSize * blockSize
+		}
+		off = next
+	}
+
+	return table, nil
+}
+
+// inflateReftableBlock decompresses the log block starting at off, returning the block with its
+// headers in front, so that record and restart offsets apply unchanged, and where the next block
+// begins.
+func inflateReftableBlock(data []byte, off, headerLen, blockLen, end int) ([]byte, int, error) {
+	if blockLen < headerLen {
+		return nil, 0, fmt.Errorf("block length %d out of range", blockLen)
+	}
+	compressed := bytes.NewReader(data[off+headerLen : end])
+	zr, err := zlib.NewReader(compressed)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer zr.Close()
+
+	block := make([]byte, blockLen)
+	copy(block, data[off:off+headerLen])
+	if _, err := io.ReadFull(zr, block[headerLen:]); err != nil {
+		return nil, 0, err
+	}
+	// Drain the stream so that its checksum is consumed and the next block can be located.
+	if _, err := io.Copy(io.Discard, zr); err != nil {
+		return nil, 0, err
+	}
+	return block, end - compressed.Len(), nil
+}
+
+// parseReftableBlock walks the prefix-compressed records of a block, up to its restart table,
+// calling parse with each record's full key, value type and remaining bytes. parse returns how
+// many bytes of the value it consumed.
+func parseReftableBlock(block []byte, headerOff int, parse func(key []byte, valueType byte, value []byte) (int, error)) error {
+	if len(block) < headerOff+6 {
+		return fmt.Errorf("block too short")
+	}
+	restartCount := int(binary.BigEndian.Uint16(block[len(block)-2:]))
+	recordsEnd := len(block) - 2 - 3*restartCount
+	if recordsEnd < headerOff+4 {
+		return fmt.Errorf("restart table out of range")
+	}
+
+	var key []byte
+	for pos := headerOff + 4; pos < recordsEnd; {
+		prefixLen, n := readReftableVarint(block[pos:recordsEnd])
+		if n == 0 {
+			return fmt.Errorf("truncated record at %d", pos)
+		}
+		pos += n
+		suffixAndType, n := readReftableVarint(block[pos:recordsEnd])
+		if n == 0 {
+			return fmt.Errorf("truncated record at %d", pos)
+		}
+		pos += n
+
+		suffixLen := int(suffixAndType >> 3)
+		if int(prefixLen) > len(key) || pos+suffixLen > recordsEnd {
+			return fmt.Errorf("invalid key at %d", pos)
+		}
+		key = append(key[:prefixLen], block[pos:pos+suffixLen]...)
+		pos += suffixLen
+
+		consumed, err := parse(key, byte(suffixAndType&0x7), block[pos:recordsEnd])
+		if err != nil {
+			return err
+		}
+		pos += consumed
+	}
+	return nil
+}
+
+// parseReftableRef decodes the value of a ref record.
+func parseReftableRef(name string, valueType byte, value []byte, hashSize int) (reftableRef, int, error) {
+	ref := reftableRef{name: name}
+	_, pos := readReftableVarint(value) // update_index delta
+	if pos == 0 {
+		return ref, 0, fmt.Errorf("truncated ref record %s", name)
+	}
+
+	readID := func() (Hash, error) {
+		if pos+hashSize > len(value) {
+			return "", fmt.Errorf("truncated ref record %s", name)
+		}
+		id, err := NewHashFromBytes(value[pos : pos+hashSize])
+		pos += hashSize
+		return id, err
+	}
+
+	var err error
+	switch valueType {
+	case reftableRefDeletion:
+		ref.deleted = true
+	case reftableRefValue:
+		ref.id, err = readID()
+	case reftableRefPeeled:
+		if ref.id, err = readID(); err == nil {
+			ref.peeled, err = readID()
+		}
+	case reftableRefSymref:
+		targetLen, n := readReftableVarint(value[pos:])
+		if n == 0 || pos+n+int(targetLen) > len(value) {
+			return ref, 0, fmt.Errorf("truncated ref record %s", name)
+		}
+		pos += n
+		ref.target = string(value[pos : pos+int(targetLen)])
+		pos += int(targetLen)
+	default:
+		err = fmt.Errorf("unknown value type %d of ref %s", valueType, name)
+	}
+	return ref, pos, err
+}
+
+// parseReftableLog decodes a log record, whose key is the ref name, a NUL byte and the bitwise
+// complement of the update index, so that the newest entry of each ref sorts first.
+func parseReftableLog(key []byte, valueType byte, value []byte, hashSize int) (reftableLog, int, error) {
+	sep := bytes.IndexByte(key, 0)
+	if sep < 0 || len(key) != sep+9 {
+		return reftableLog{}, 0, fmt.Errorf("invalid log key %q", key)
+	}
+	entry := reftableLog{
+		name:        string(key[:sep]),
+		updateIndex: ^binary.BigEndian.Uint64(key[sep+1:]),
+	}
+
+	switch valueType {
+	case reftableLogDeletion:
+		entry.deleted = true
+		return entry, 0, nil
+	case reftableLogUpdate:
+	default:
+		return entry, 0, fmt.Errorf("unknown value type %d of log %s", valueType, entry.name)
+	}
+
+	errTruncated := fmt.Errorf("truncated log record of %s", entry.name)
+	if len(value) < 2*hashSize {
+		return entry, 0, errTruncated
+	}
+	oldID, err := NewHashFromBytes(value[:hashSize])
+	if err != nil {
+		return entry, 0, err
+	}
+	newID, err := NewHashFromBytes(value[hashSize : 2*hashSize])
+	if err != nil {
+		return entry, 0, err
+	}
+	pos := 2 * hashSize
+
+	readString := func() (string, bool) {
+		length, n := readReftableVarint(value[pos:])
+		if n == 0 || pos+n+int(length) > len(value) {
+			return "", false
+		}
+		pos += n + int(length)
+		return string(value[pos-int(length) : pos]), true
+	}
+
+	name, ok := readString()
+	if !ok {
+		return entry, 0, errTruncated
+	}
+	email, ok := readString()
+	if !ok {
+		return entry, 0, errTruncated
+	}
+	seconds, n := readReftableVarint(value[pos:])
+	if n == 0 || pos+n+2 > len(value) {
+		return entry, 0, errTruncated
+	}
+	pos += n
+	tzOffset := int(int16(binary.BigEndian.Uint16(value[pos:])))
+	pos += 2
+	message, ok := readString()
+	if !ok {
+		return entry, 0, errTruncated
+	}
+
+	entry.entry = ReflogEntry{
+		Old: oldID,
+		New: newID,
+		Committer: Signature{
+			Name:  name,
+			Email: email,
+			When:  time.Unix(int64(seconds), 0).In(reftableTimezone(tzOffset)),
+		},
+		Message: strings.TrimSuffix(message, "\n"),
+	}
+	return entry, pos, nil
+}
+
+// reftableTimezone converts a time zone offset stored as in git's ident lines, e.g. -700 for
+// "-0700", into a location named like the ones parseTimezone returns.
+func reftableTimezone(offset int) *time.Location {
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	hours, minutes := offset/100, offset%100
+	seconds := hours*3600 + minutes*60
+	if sign == '-' {
+		seconds = -seconds
+	}
+	return time.FixedZone(fmt.Sprintf("%c%02d%02d", sign, hours, minutes), seconds)
+}
+
+// readReftableVarint decodes a variable-length integer in the encoding git also uses for
+// OFS_DELTA offsets. It returns the value and the number of bytes read, which is 0 if b is
+// truncated.
+func readReftableVarint(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	value := uint64(b[0] & 0x7f)
+	n := 1
+	for b[n-1]&0x80 != 0 {
+		if n >= len(b) {
+			return 0, 0
+		}
+		value = ((value + 1) << 7) | uint64(b[n]&0x7f)
+		n++
+	}
+	return value, n
+}
@@ -0,0 +1,103 @@
+package gitcore
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// The stack in testdata/reftable-repo is written by testdata/genreftable.go. Its first table
+// holds a symbolic HEAD, two branches, a dangling symbolic ref and a peeled tag; its second moves
+// main and deletes old. Each table is one ref block padded to the block size and one deflated log
+// block.
+const (
+	fixtureCommitA Hash = "1111111111111111111111111111111111111111"
+	fixtureCommitB Hash = "2222222222222222222222222222222222222222"
+	fixtureTagV1   Hash = "3333333333333333333333333333333333333333"
+	fixtureZero    Hash = "0000000000000000000000000000000000000000"
+)
+
+func newReftableFixture(t *testing.T) *Repository {
+	t.Helper()
+	dir := filepath.Join("testdata", "reftable-repo")
+	return &Repository{
+		gitDir:       dir,
+		commonDir:    dir,
+		objectFormat: SHA1,
+		refStorage:   RefStorageReftable,
+		refs:         make(map[string]Hash),
+	}
+}
+
+func TestLoadReftableRefs(t *testing.T) {
+	repo := newReftableFixture(t)
+	if err := repo.loadReftable(); err != nil {
+		t.Fatalf("loadReftable: %v", err)
+	}
+
+	want := map[string]Hash{
+		"refs/heads/main": fixtureCommitB,
+		"refs/tags/v1":    fixtureTagV1,
+	}
+	if len(repo.refs) != len(want) {
+		t.Errorf("got refs %v, want %v", repo.refs, want)
+	}
+	for name, id := range want {
+		if repo.refs[name] != id {
+			t.Errorf("ref %s = %q, want %q", name, repo.refs[name], id)
+		}
+	}
+	if _, found := repo.refs["refs/heads/old"]; found {
+		t.Error("deleted ref refs/heads/old was loaded")
+	}
+	if _, found := repo.refs["refs/remotes/origin/HEAD"]; found {
+		t.Error("dangling symbolic ref refs/remotes/origin/HEAD was loaded")
+	}
+
+	if peeled := repo.peeledTags[fixtureTagV1]; peeled != fixtureCommitA {
+		t.Errorf("peeled value of %s = %q, want %q", fixtureTagV1, peeled, fixtureCommitA)
+	}
+}
+
+func TestReadWorktreeHeadReftable(t *testing.T) {
+	repo := newReftableFixture(t)
+	target, id, err := repo.readWorktreeHead(repo.gitDir)
+	if err != nil {
+		t.Fatalf("readWorktreeHead: %v", err)
+	}
+	if target != "refs/heads/main" || id != "" {
+		t.Errorf("HEAD = (%q, %q), want symbolic ref to refs/heads/main", target, id)
+	}
+}
+
+func TestLoadReftableReflogs(t *testing.T) {
+	repo := newReftableFixture(t)
+	if err := repo.loadReftable(); err != nil {
+		t.Fatalf("loadReftable: %v", err)
+	}
+
+	main := repo.reflogs["refs/heads/main"]
+	if len(main) != 2 {
+		t.Fatalf("got %d reflog entries for refs/heads/main, want 2", len(main))
+	}
+	// Oldest first, across tables.
+	if main[0].Old != fixtureZero || main[0].New != fixtureCommitA || main[0].Message != "commit (initial): one" {
+		t.Errorf("first entry = %+v", main[0])
+	}
+	if main[1].Old != fixtureCommitA || main[1].New != fixtureCommitB || main[1].Message != "commit: two" {
+		t.Errorf("second entry = %+v", main[1])
+	}
+
+	when := main[0].Committer.When
+	if when.Unix() != 1700000000 || when.Location().String() != "+0200" {
+		t.Errorf("first entry time = %v, want 1700000000 +0200", when)
+	}
+	if old := repo.reflogs["refs/heads/old"]; len(old) != 1 {
+		t.Errorf("got %d reflog entries for refs/heads/old, want 1", len(old))
+	} else if name, _ := old[0].Committer.When.Zone(); name != "-0700" {
+		t.Errorf("refs/heads/old entry zone = %s, want -0700", name)
+	}
+
+	if head := repo.reflogs["HEAD"]; len(head) != 2 || head[1].New != fixtureCommitB {
+		t.Errorf("HEAD reflog = %+v, want 2 entries ending at %s", head, fixtureCommitB)
+	}
+}
@@ -0,0 +1,118 @@
+package gitcore
+
+import (
+	"strings"
+)
+
+// remoteRefPrefix is the namespace of remote-tracking branches.
+const remoteRefPrefix = "refs/remotes/"
+
+// Remote is a named remote repository from the [remote "<name>"] section of the config.
+type Remote struct {
+	Name     string   `json:"name"`
+	URLs     []string `json:"urls"`
+	PushURLs []string `json:"pushUrls,omitempty"`
+	Fetch    []string `json:"fetch"`
+}
+
+// BranchConfig is the upstream configuration of a local branch, from its [branch "<name>"] section.
+// Remote is "." when the branch tracks another local branch.
+type BranchConfig struct {
+	Remote string `json:"remote"`
+	Merge  string `json:"merge"`
+}
+
+// loadRemoteConfig reads the [remote] and [branch] sections of the repository's config.
+// See: https://git-scm.com/docs/git-config#Documentation/git-config.txt-remoteltnamegturl
+func (r *Repository) loadRemoteConfig() {
+	r.remotes = make(map[string]*Remote)
+	for _, name := range r.config.Subsections("remote") {
+		r.remotes[name] = &Remote{
+			Name:     name,
+			URLs:     r.config.GetAll("remote", name, "url"),
+			PushURLs: r.config.GetAll("remote", name, "pushurl"),
+			Fetch:    r.config.GetAll("remote", name, "fetch"),
+		}
+	}
+
+	r.branchConfigs = make(map[string]*BranchConfig)
+	for _, name := range r.config.Subsections("branch") {
+		remote, _ := r.config.Get("branch", name, "remote")
+		merge, _ := r.config.Get("branch", name, "merge")
+		r.branchConfigs[name] = &BranchConfig{Remote: remote, Merge: merge}
+	}
+}
+
+// Remotes returns all configured remotes by name.
+func (r *Repository) Remotes() map[string]*Remote {
+	return r.remotes
+}
+
+// RemoteBranches returns a map of remote-tracking branch names (e.g. "origin/main") to Commit
+// hashes. Symbolic remote HEADs such as origin/HEAD are omitted.
+func (r *Repository) RemoteBranches() map[string]Hash {
+	result := make(map[string]Hash)
+	for ref, hash := range r.refs {
+		if !strings.HasPrefix(ref, remoteRefPrefix) || strings.HasSuffix(ref, "/HEAD") {
+			continue
+		}
+		result[strings.TrimPrefix(ref, remoteRefPrefix)] = hash
+	}
+	return result
+}
+
+// Upstream returns the full name of the ref a local branch tracks, e.g. refs/remotes/origin/main,
+// mapping the configured merge ref through the remote's fetch refspecs.
+// Returns false if the branch has no upstream or it is never fetched into a local ref.
+func (r *Repository) Upstream(branch string) (string, bool) {
+	config, found := r.branchConfigs[branch]
+	if !found || config.Remote == "" || config.Merge == "" {
+		return "", false
+	}
+	if config.Remote == "." {
+		return config.Merge, true
+	}
+
+	remote, found := r.remotes[config.Remote]
+	if !found {
+		return "", false
+	}
+	for _, refspec := range remote.Fetch {
+		if dst, ok := mapRefspec(refspec, config.Merge); ok {
+			return dst, true
+		}
+	}
+	return "", false
+}
+
+// Upstreams returns the upstream ref of every local branch that has one.
+func (r *Repository) Upstreams() map[string]string {
+	result := make(map[string]string)
+	for branch := range r.branchConfigs {
+		if upstream, ok := r.Upstream(branch); ok {
+			result[branch] = upstream
+		}
+	}
+	return result
+}
+
+// mapRefspec maps a remote ref to its local destination through a fetch refspec such as
+// "+refs/heads/*:refs/remotes/origin/*". Returns false if the refspec does not match.
+// See: https://git-scm.com/book/en/v2/Git-Internals-The-Refspec
+func mapRefspec(refspec, ref string) (string, bool) {
+	src, dst, found := strings.Cut(strings.TrimPrefix(refspec, "+"), ":")
+	if !found || strings.HasPrefix(src, "^") {
+		return "", false
+	}
+
+	srcPrefix, srcSuffix, srcWildcard := strings.Cut(src, "*")
+	if !srcWildcard {
+		return dst, ref == src
+	}
+	if !strings.HasPrefix(ref, srcPrefix) || !strings.HasSuffix(ref, srcSuffix) ||
+		len(ref) < len(srcPrefix)+len(srcSuffix) {
+		return "", false
+	}
+	match := ref[len(srcPrefix) : len(ref)-len(srcSuffix)]
+	return strings.Replace(dst, "*", match, 1), true
+}
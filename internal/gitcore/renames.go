@@ -0,0 +1,260 @@
+package gitcore
+
+import (
+	"bytes"
+	"hash/fnv"
+	"path"
+	"sort"
+)
+
+// renameChunkSize bounds the length of a content chunk when scoring similarity, so that binary
+// files and files with very long lines still split into comparable pieces.
+const renameChunkSize = 64
+
+// RenameOptions configures rename and copy detection in tree diffs.
+// Threshold is the minimum similarity percentage for an inexact pair, like git's -M50%.
+// Limit caps the number of sources and destinations considered for inexact matching, like
+// diff.renameLimit; exact matches are always detected.
+type RenameOptions struct {
+	Renames   bool
+	Copies    bool
+	Threshold int
+	Limit     int
+}
+
+// DefaultRenameOptions returns the rename detection git diff performs by default.
+func DefaultRenameOptions() RenameOptions {
+	return RenameOptions{Renames: true, Threshold: 50, Limit: 1000}
+}
+
+// renameCandidate is a possible pairing of a source and destination change.
+type renameCandidate struct {
+	src, dst int
+	score    int
+	sameName bool
+}
+
+// DetectRenames pairs deleted and added paths in changes into renames, and, when enabled, pairs
+// added paths with deleted or modified sources into copies.
+// Identical content is matched first; the remaining paths are scored by content similarity.
+func (r *Repository) DetectRenames(changes []FileChange, opts RenameOptions) ([]FileChange, error) {
+	if !opts.Renames && !opts.Copies {
+		return changes, nil
+	}
+
+	var sources, destinations []int
+	for i, change := range changes {
+		switch {
+		case change.Type == ChangeAdded:
+			destinations = append(destinations, i)
+		case change.Type == ChangeDeleted:
+			sources = append(sources, i)
+		case change.Type == ChangeModified && opts.Copies:
+			sources = append(sources, i)
+		}
+	}
+	if len(sources) == 0 || len(destinations) == 0 {
+		return changes, nil
+	}
+
+	paired := make(map[int]FileChange)
+	renamed := make(map[int]bool)
+	canRename := func(src int) bool {
+		return opts.Renames && changes[src].Type == ChangeDeleted && !renamed[src]
+	}
+	pair := func(src, dst, score int) {
+		source, dest := changes[src], changes[dst]
+		change := FileChange{
+			Type:       ChangeCopied,
+			Path:       dest.Path,
+			OldPath:    source.Path,
+			OldMode:    source.OldMode,
+			NewMode:    dest.NewMode,
+			OldHash:    source.OldHash,
+			NewHash:    dest.NewHash,
+			Similarity: score,
+		}
+		if canRename(src) {
+			change.Type = ChangeRenamed
+			renamed[src] = true
+		}
+		paired[dst] = change
+	}
+
+	// Exact matches, preferring a source that can be renamed, then one with the same base name.
+	bySourceHash := make(map[Hash][]int)
+	for _, src := range sources {
+		bySourceHash[changes[src].OldHash] = append(bySourceHash[changes[src].OldHash], src)
+	}
+	for _, dst := range destinations {
+		dest := changes[dst]
+		best, bestRank := -1, -1
+		for _, src := range bySourceHash[dest.NewHash] {
+			if changes[src].OldMode.format() != dest.NewMode.format() {
+				continue
+			}
+			rank := 0
+			if canRename(src) {
+				rank += 2
+			} else if !opts.Copies {
+				continue
+			}
+			if path.Base(changes[src].Path) == path.Base(dest.Path) {
+				rank++
+			}
+			if rank > bestRank {
+				best, bestRank = src, rank
+			}
+		}
+		if best != -1 {
+			pair(best, dst, 100)
+		}
+	}
+
+	// Inexact matches, scored by content similarity. Renames are assigned before copies so that a
+	// deleted file is paired with its best destination rather than copied into several.
+	var remainingSrc, remainingDst []int
+	for _, src := range sources {
+		if canRename(src) || opts.Copies {
+			remainingSrc = append(remainingSrc, src)
+		}
+	}
+	for _, dst := range destinations {
+		if _, done := paired[dst]; !done {
+			remainingDst = append(remainingDst, dst)
+		}
+	}
+	withinLimit := opts.Limit <= 0 || len(remainingSrc)*len(remainingDst) <= opts.Limit*opts.Limit
+	if len(remainingSrc) > 0 && len(remainingDst) > 0 && withinLimit {
+		candidates, err := r.scoreRenames(changes, remainingSrc, remainingDst, opts.Threshold)
+		if err != nil {
+			return nil, err
+		}
+		for _, candidate := range candidates {
+			if _, done := paired[candidate.dst]; !done && canRename(candidate.src) {
+				pair(candidate.src, candidate.dst, candidate.score)
+			}
+		}
+		if opts.Copies {
+			for _, candidate := range candidates {
+				if _, done := paired[candidate.dst]; !done {
+					pair(candidate.src, candidate.dst, candidate.score)
+				}
+			}
+		}
+	}
+
+	if len(paired) == 0 {
+		return changes, nil
+	}
+
+	result := make([]FileChange, 0, len(changes))
+	for i, change := range changes {
+		if p, found := paired[i]; found {
+			result = append(result, p)
+		} else if !renamed[i] {
+			result = append(result, change)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result, nil
+}
+
+// scoreRenames computes the similarity of every source and destination pair whose sizes make a
+// match above threshold possible, returning candidates above threshold from best to worst.
+func (r *Repository) scoreRenames(changes []FileChange, sources, destinations []int, threshold int) ([]renameCandidate, error) {
+	signatures := make(map[Hash]*contentSignature)
+	signature := func(id Hash) (*contentSignature, error) {
+		if sig, found := signatures[id]; found {
+			return sig, nil
+		}
+		content, _, err := r.readBlobContent(id)
+		if err != nil {
+			return nil, err
+		}
+		sig := newContentSignature(content)
+		signatures[id] = sig
+		return sig, nil
+	}
+
+	var candidates []renameCandidate
+	for _, dst := range destinations {
+		dest := changes[dst]
+		if dest.NewMode.Kind() != BlobObject {
+			continue
+		}
+		destSig, err := signature(dest.NewHash)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, src := range sources {
+			source := changes[src]
+			if source.OldMode.format() != dest.NewMode.format() {
+				continue
+			}
+			srcSig, err := signature(source.OldHash)
+			if err != nil {
+				return nil, err
+			}
+
+			larger, smaller := max(srcSig.size, destSig.size), min(srcSig.size, destSig.size)
+			if larger == 0 || smaller*100 < larger*int64(threshold) {
+				continue
+			}
+			score := int(srcSig.common(destSig) * 100 / larger)
+			if score >= threshold {
+				candidates = append(candidates, renameCandidate{
+					src:      src,
+					dst:      dst,
+					score:    score,
+					sameName: path.Base(source.Path) == path.Base(dest.Path),
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].sameName && !candidates[j].sameName
+	})
+	return candidates, nil
+}
+
+// contentSignature summarises file content as the number of bytes in each distinct chunk, where
+// chunks are lines capped at renameChunkSize bytes. Similar to git's diffcore-delta spans.
+type contentSignature struct {
+	size   int64
+	chunks map[uint64]int64
+}
+
+// newContentSignature builds the chunk signature of content.
+func newContentSignature(content []byte) *contentSignature {
+	sig := &contentSignature{size: int64(len(content)), chunks: make(map[uint64]int64)}
+	for len(content) > 0 {
+		n := len(content)
+		if nl := bytes.IndexByte(content, '\n'); nl != -1 && nl+1 < n {
+			n = nl + 1
+		}
+		n = min(n, renameChunkSize)
+
+		h := fnv.New64a()
+		h.Write(content[:n])
+		sig.chunks[h.Sum64()] += int64(n)
+		content = content[n:]
+	}
+	return sig
+}
+
+// common returns the number of bytes of content shared between two signatures.
+func (s *contentSignature) common(other *contentSignature) int64 {
+	var shared int64
+	for chunk, size := range s.chunks {
+		shared += min(size, other.chunks[chunk])
+	}
+	return shared
+}
@@ -0,0 +1,107 @@
+package gitcore
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// replaceRefPrefix is the namespace of refs that substitute one object for another.
+const replaceRefPrefix = "refs/replace/"
+
+// maxReplaceDepth bounds how many replacements are followed for one object, matching git.
+const maxReplaceDepth = 5
+
+// loadReplaceRefs collects refs/replace/*, each named after the object it replaces and pointing
+// at its replacement. Replace refs are removed from the ordinary refs, since the replacement
+// objects are reached through the objects they stand in for rather than as history of their own.
+// See: https://git-scm.com/docs/git-replace
+func (r *Repository) loadReplaceRefs() {
+	for refName, hash := range r.refs {
+		if !strings.HasPrefix(refName, replaceRefPrefix) {
+			continue
+		}
+		delete(r.refs, refName)
+		if r.options.NoReplaceObjects {
+			continue
+		}
+
+		original, err := NewHash(strings.TrimPrefix(refName, replaceRefPrefix))
+		if err != nil {
+			// Log the error but continue with other potentially valid replace refs.
+			log.Printf("ignoring replace ref %s: %v", refName, err)
+			continue
+		}
+		if r.replacements == nil {
+			r.replacements = make(map[Hash]Hash)
+		}
+		r.replacements[original] = hash
+	}
+}
+
+// loadGrafts reads the legacy info/grafts file, where each line names a commit followed by the
+// parents that override its recorded ones.
+// See: https://git-scm.com/docs/gitrepository-layout#Documentation/gitrepository-layout.txt-infografts
+func (r *Repository) loadGrafts() error {
+	file, err := os.Open(filepath.Join(r.commonDir, "info", "grafts"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		ids := make([]Hash, 0, len(fields))
+		for _, field := range fields {
+			id, err := NewHash(field)
+			if err != nil {
+				return fmt.Errorf("invalid graft %q: %w", line, err)
+			}
+			ids = append(ids, id)
+		}
+		if r.grafts == nil {
+			r.grafts = make(map[Hash][]Hash)
+		}
+		r.grafts[ids[0]] = ids[1:]
+	}
+
+	return scanner.Err()
+}
+
+// replacementFor returns the object whose content stands in for id, following chains of
+// replacements. Returns id itself when it is not replaced.
+func (r *Repository) replacementFor(id Hash) Hash {
+	for depth := 0; depth < maxReplaceDepth; depth++ {
+		replacement, found := r.replacements[id]
+		if !found {
+			return id
+		}
+		id = replacement
+	}
+	log.Printf("replace depth too high for object %s", id)
+	return id
+}
+
+// applyGraft overrides a commit's parents with its graft, if it has one.
+func (r *Repository) applyGraft(commit *Commit) {
+	if parents, found := r.grafts[commit.ID]; found {
+		commit.Parents = parents
+	}
+}
+
+// rewritesHistory reports whether replace refs or grafts change any commit's content, in which
+// case the commit-graph, written from the original objects, cannot be trusted.
+func (r *Repository) rewritesHistory() bool {
+	return len(r.replacements) > 0 || len(r.grafts) > 0
+}
@@ -1,22 +1,58 @@
 package gitcore
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+
+	"github.com/rybkr/gitvista/internal/gitcore/config"
 )
 
+// Options control how a Repository interprets its contents.
+type Options struct {
+	// NoReplaceObjects ignores refs/replace/*, like git --no-replace-objects.
+	NoReplaceObjects bool
+	// Refs selects which refs are loaded and traversed. The zero value loads all of refs/.
+	Refs RefFilter
+}
+
 // Repository represents a Git repository with its metadata and object storage.
 type Repository struct {
-	gitDir  string
-	workDir string
+	gitDir       string
+	commonDir    string
+	workDir      string
+	objectFormat ObjectFormat
+	refStorage   RefStorage
+	options      Options
+	config       *config.Config
 
-	packIndices []*PackIndex
-	refs        map[string]Hash
-	commits     []*Commit
-	tags        []*Tag
+	objectDirs       []string
+	packIndices      []*PackIndex
+	multiPackIndices []*MultiPackIndex
+	packFiles        *packFilePool
+	deltaBases       *deltaBaseCache
+	refs             map[string]Hash
+	commits          []*Commit
+	commitIndex      map[Hash]*Commit
+	commitGraph      *commitGraph
+	shallow          map[Hash]bool
+	replacements     map[Hash]Hash
+	grafts           map[Hash][]Hash
+	notesRefs        map[string]Hash
+	notes            map[string]map[Hash]Hash
+	reflogs          map[string][]ReflogEntry
+	remotes          map[string]*Remote
+	branchConfigs    map[string]*BranchConfig
+	stashHead        Hash
+	stashes          []*Stash
+	worktrees        []*Worktree
+	submodules       []*Submodule
+	tags             []*Tag
+	tagRefs          map[string]*TagRef
+	peeledTags       map[Hash]Hash
 
 	head         Hash
 	headRef      string
@@ -30,46 +66,143 @@ type Repository struct {
 //   - The working directory (will find .git within)
 //   - The .git directory itself
 //   - A parent directory containing a .git directory
+//
+// Replace refs are honored unless the GIT_NO_REPLACE_OBJECTS environment variable is set.
 func NewRepository(path string) (*Repository, error) {
+	return NewRepositoryWithOptions(path, Options{
+		NoReplaceObjects: os.Getenv("GIT_NO_REPLACE_OBJECTS") != "",
+	})
+}
+
+// NewRepositoryWithOptions creates and initializes a new Repository instance with explicit options.
+// path is interpreted as in NewRepository.
+func NewRepositoryWithOptions(path string, options Options) (*Repository, error) {
 	gitDir, workDir, err := findGitDirectory(path)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := validateGitDirectory(gitDir); err != nil {
+	commonDir, err := readCommonDir(gitDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commondir: %w", err)
+	}
+	if err := validateGitDirectory(gitDir, commonDir); err != nil {
+		return nil, err
+	}
+	if options.Refs, err = options.Refs.Compile(); err != nil {
 		return nil, err
 	}
 
 	repo := &Repository{
-		gitDir:  gitDir,
-		workDir: workDir,
-		refs:    make(map[string]Hash),
-		commits: make([]*Commit, 0),
+		gitDir:      gitDir,
+		commonDir:   commonDir,
+		workDir:     workDir,
+		options:     options,
+		refs:        make(map[string]Hash),
+		commits:     make([]*Commit, 0),
+		commitIndex: make(map[Hash]*Commit),
+		packFiles:   newPackFilePool(),
+		deltaBases:  newDeltaBaseCache(deltaBaseCacheLimit),
 	}
 
+	if repo.config, err = config.Load(config.LoadOptions{
+		GitDir:    gitDir,
+		CommonDir: commonDir,
+		Branch:    readHeadBranch(gitDir),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if workDir == gitDir && !repo.IsBare() {
+		// A git directory opened directly, such as a submodule's under modules/, names its
+		// checkout in core.worktree.
+		if path, found := repo.configuredWorktree(); found {
+			repo.workDir = path
+		}
+	}
+	if repo.objectFormat, err = readObjectFormat(repo.config); err != nil {
+		return nil, fmt.Errorf("failed to read object format: %w", err)
+	}
+	if repo.refStorage, err = readRefStorage(repo.config); err != nil {
+		return nil, fmt.Errorf("failed to read ref storage: %w", err)
+	}
+	repo.loadObjectDirs()
 	if err := repo.loadPackIndices(); err != nil {
 		return nil, fmt.Errorf("failed to load pack indices: %w", err)
 	}
 	if err := repo.loadRefs(); err != nil {
 		return nil, fmt.Errorf("failed to load refs: %w", err)
 	}
+	repo.loadRemoteConfig()
+	if err := repo.loadGrafts(); err != nil {
+		return nil, fmt.Errorf("failed to load grafts: %w", err)
+	}
+	if err := repo.loadReflogs(); err != nil {
+		return nil, fmt.Errorf("failed to load reflogs: %w", err)
+	}
 	if err := repo.loadObjects(); err != nil {
 		return nil, fmt.Errorf("failed to load objects: %w", err)
 	}
+	repo.loadSubmodules()
 
 	return repo, nil
 }
 
+// Close closes the pooled pack files and unmaps the pack indices. A Repository is reloaded by
+// opening a new one, so the old one should be closed once nothing reads from it any longer.
+func (r *Repository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	errs := []error{r.packFiles.close()}
+	for _, idx := range r.packIndices {
+		errs = append(errs, idx.close())
+	}
+	r.packIndices, r.multiPackIndices = nil, nil
+	return errors.Join(errs...)
+}
+
 // Name returns the repository's directory name.
 func (r *Repository) Name() string {
 	return filepath.Base(r.workDir)
 }
 
 // GitDir returns the path to the repository's .git folder.
+// For a linked worktree, this is the worktree's private directory under worktrees/.
 func (r *Repository) GitDir() string {
 	return r.gitDir
 }
 
+// CommonDir returns the path to the directory holding objects, refs and configuration shared by
+// all worktrees. It equals GitDir except in a linked worktree.
+func (r *Repository) CommonDir() string {
+	return r.commonDir
+}
+
+// ObjectFormat returns the hash algorithm the repository uses to name objects.
+func (r *Repository) ObjectFormat() ObjectFormat {
+	return r.objectFormat
+}
+
+// RefStorage returns the backend the repository stores its refs in.
+func (r *Repository) RefStorage() RefStorage {
+	return r.refStorage
+}
+
+// Config returns the merged system, global and repository configuration.
+func (r *Repository) Config() *config.Config {
+	return r.config
+}
+
+// IsBare reports whether the repository is bare, according to core.bare.
+func (r *Repository) IsBare() bool {
+	return r.config.Bool("core", "", "bare", false)
+}
+
+// Options returns the options the repository was opened with.
+func (r *Repository) Options() Options {
+	return r.options
+}
+
 // Commits returns a map of all commit IDs to Commit structs.
 func (r *Repository) Commits() map[Hash]*Commit {
 	result := make(map[Hash]*Commit)
@@ -79,6 +212,47 @@ func (r *Repository) Commits() map[Hash]*Commit {
 	return result
 }
 
+// Commit returns the fully parsed commit with the given hash.
+// Commits that were loaded Partial from the commit-graph are read from the object store, keeping
+// their generation number.
+func (r *Repository) Commit(id Hash) (*Commit, error) {
+	indexed, found := r.commitIndex[id]
+	if found && !indexed.Partial {
+		return indexed, nil
+	}
+
+	object, err := r.readObject(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", id, err)
+	}
+	commit, ok := object.(*Commit)
+	if !ok {
+		return nil, fmt.Errorf("object %s is not a commit", id)
+	}
+	if found {
+		commit.Generation = indexed.Generation
+	}
+	r.attachNotes(commit)
+	return commit, nil
+}
+
+// Tree reads and returns the tree object with the given hash.
+func (r *Repository) Tree(id Hash) (*Tree, error) {
+	object, err := r.readObject(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree %s: %w", id, err)
+	}
+	if object == nil {
+		return nil, fmt.Errorf("tree not found: %s", id)
+	}
+
+	tree, ok := object.(*Tree)
+	if !ok {
+		return nil, fmt.Errorf("object %s is not a tree", id)
+	}
+	return tree, nil
+}
+
 // Branches returns a map of all branch names to Commit hashes.
 func (r *Repository) Branches() map[string]Hash {
     result := make(map[string]Hash)
@@ -122,6 +296,99 @@ func (r *Repository) Diff(old *Repository) *RepositoryDelta {
         }
     }
 
+	newRemoteBranches, oldRemoteBranches := r.RemoteBranches(), old.RemoteBranches()
+	for branch, hash := range newRemoteBranches {
+		if oldHash, found := oldRemoteBranches[branch]; !found {
+			delta.AddedRemoteBranches[branch] = hash
+		} else if hash != oldHash {
+			delta.AmendedRemoteBranches[branch] = hash
+		}
+	}
+	for branch, hash := range oldRemoteBranches {
+		if _, found := newRemoteBranches[branch]; !found {
+			delta.DeletedRemoteBranches[branch] = hash
+		}
+	}
+
+	otherRefs := RefFilter{Kinds: []RefKind{RefPullRequest, RefChange, RefOther}}
+	newRefs, oldRefs := refsByName(r.Refs(otherRefs)), refsByName(old.Refs(otherRefs))
+	for name, ref := range newRefs {
+		if oldRef, found := oldRefs[name]; !found {
+			delta.AddedRefs[name] = ref
+		} else if ref.Hash != oldRef.Hash {
+			delta.AmendedRefs[name] = ref
+		}
+	}
+	for name, ref := range oldRefs {
+		if _, found := newRefs[name]; !found {
+			delta.DeletedRefs[name] = ref
+		}
+	}
+
+	newTags, oldTags := r.tagRefs, old.tagRefs
+	for name, tag := range newTags {
+		if oldTag, found := oldTags[name]; !found {
+			delta.AddedTags[name] = tag
+		} else if tag.Hash != oldTag.Hash {
+			delta.AmendedTags[name] = tag
+		}
+	}
+	for name, tag := range oldTags {
+		if _, found := newTags[name]; !found {
+			delta.DeletedTags[name] = tag
+		}
+	}
+
+	newWorktrees, oldWorktrees := worktreesByPath(r.worktrees), worktreesByPath(old.worktrees)
+	for path, wt := range newWorktrees {
+		if oldWorktree, found := oldWorktrees[path]; !found {
+			delta.AddedWorktrees[path] = wt
+		} else if *wt != *oldWorktree {
+			delta.AmendedWorktrees[path] = wt
+		}
+	}
+	for path, wt := range oldWorktrees {
+		if _, found := newWorktrees[path]; !found {
+			delta.DeletedWorktrees[path] = wt
+		}
+	}
+
+	newStashes, oldStashes := stashesByID(r.stashes), stashesByID(old.stashes)
+	for id, stash := range newStashes {
+		if oldStash, found := oldStashes[id]; !found || oldStash.Name != stash.Name {
+			delta.AddedStashes = append(delta.AddedStashes, stash)
+		}
+	}
+	for id, stash := range oldStashes {
+		if newStash, found := newStashes[id]; !found || newStash.Name != stash.Name {
+			delta.DeletedStashes = append(delta.DeletedStashes, stash)
+		}
+	}
+
+	for hash := range r.shallow {
+		if !old.shallow[hash] {
+			delta.AddedShallows = append(delta.AddedShallows, hash)
+		}
+	}
+	for hash := range old.shallow {
+		if !r.shallow[hash] {
+			delta.DeletedShallows = append(delta.DeletedShallows, hash)
+		}
+	}
+
+	for _, id := range r.changedNotes(old) {
+		commit, found := r.commitIndex[id]
+		if _, existed := old.commitIndex[id]; !found || !existed {
+			// Added commits carry their notes already.
+			continue
+		}
+		notes := commit.Notes
+		if notes == nil {
+			notes = make(map[string]string)
+		}
+		delta.UpdatedNotes[id] = notes
+	}
+
 	return delta
 }
 
@@ -133,10 +400,18 @@ func findGitDirectory(startPath string) (gitDir string, workDir string, err erro
 		return "", "", fmt.Errorf("failed to resolve path: %w", err)
 	}
 
-	if filepath.Base(absPath) == ".git" {
-		info, err := os.Stat(absPath)
-		if err == nil && info.IsDir() {
+	if isGitDirectory(absPath) {
+		switch {
+		case filepath.Base(absPath) == ".git":
 			return absPath, filepath.Dir(absPath), nil
+		case filepath.Base(filepath.Dir(absPath)) == "worktrees":
+			// The private directory of a linked worktree, e.g. when reopening from GitDir.
+			if workDir, err := linkedWorktreePath(absPath); err == nil {
+				return absPath, workDir, nil
+			}
+		default:
+			// A bare repository has no working directory; it is named after itself.
+			return absPath, absPath, nil
 		}
 	}
 
@@ -162,7 +437,8 @@ func findGitDirectory(startPath string) (gitDir string, workDir string, err erro
 }
 
 // handleGitFile handles the case where .git is a file (worktrees, submodules).
-// .git file format: "gitdir: /path/to/actual/.git".
+// .git file format: "gitdir: /path/to/actual/.git". For a linked worktree, the gitdir is the
+// worktree's private directory, whose commondir file leads to the shared repository.
 func handleGitFile(gitFilePath string, workDir string) (string, string, error) {
 	content, err := os.ReadFile(gitFilePath)
 	if err != nil {
@@ -188,7 +464,8 @@ func handleGitFile(gitFilePath string, workDir string) (string, string, error) {
 }
 
 // validateGitDirectory checks if the directory is a valid Git repository.
-func validateGitDirectory(gitDir string) error {
+// HEAD is kept in the git directory, while objects and refs may be in a separate common directory.
+func validateGitDirectory(gitDir, commonDir string) error {
 	info, err := os.Stat(gitDir)
 	if err != nil {
 		return fmt.Errorf("git directory does not exist: %w", err)
@@ -197,9 +474,9 @@ func validateGitDirectory(gitDir string) error {
 		return fmt.Errorf("git path is not a directory: %s", gitDir)
 	}
 
-	requiredPaths := []string{"objects", "refs", "HEAD"}
-	for _, required := range requiredPaths {
-		path := filepath.Join(gitDir, required)
+	requiredPaths := map[string]string{"objects": commonDir, "refs": commonDir, "HEAD": gitDir}
+	for required, dir := range requiredPaths {
+		path := filepath.Join(dir, required)
 		if _, err := os.Stat(path); err != nil {
 			return fmt.Errorf("invalid git repository, missing: %s", required)
 		}
@@ -207,3 +484,49 @@ func validateGitDirectory(gitDir string) error {
 
 	return nil
 }
+
+// readObjectFormat determines the repository's object format from extensions.objectFormat in its
+// own config, defaulting to SHA-1 when unset.
+// See: https://git-scm.com/docs/git-config#Documentation/git-config.txt-extensionsobjectFormat
+func readObjectFormat(cfg *config.Config) (ObjectFormat, error) {
+	value, found := cfg.Scope(config.ScopeLocal).Get("extensions", "", "objectformat")
+	if !found {
+		return SHA1, nil
+	}
+	switch format := ObjectFormat(strings.ToLower(value)); format {
+	case SHA1, SHA256:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported object format: %s", format)
+	}
+}
+
+// readRefStorage determines the repository's ref backend from extensions.refStorage in its own
+// config, defaulting to the files backend when unset.
+func readRefStorage(cfg *config.Config) (RefStorage, error) {
+	value, found := cfg.Scope(config.ScopeLocal).Get("extensions", "", "refstorage")
+	if !found {
+		return RefStorageFiles, nil
+	}
+	switch storage := RefStorage(strings.ToLower(value)); storage {
+	case RefStorageFiles, RefStorageReftable:
+		return storage, nil
+	default:
+		return "", fmt.Errorf("unsupported ref storage: %s", storage)
+	}
+}
+
+// readHeadBranch returns the short name of the branch HEAD points to, or the empty string when
+// HEAD is detached. Config is read before refs, so HEAD is read directly for includeIf "onbranch:".
+func readHeadBranch(gitDir string) string {
+	content, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	ref, found := strings.CutPrefix(strings.TrimSpace(string(content)), "ref: ")
+	if !found || ref == "refs/heads/.invalid" {
+		// Reftable repositories keep the real HEAD in the reftable and this placeholder on disk.
+		return ""
+	}
+	return strings.TrimPrefix(ref, "refs/heads/")
+}
@@ -0,0 +1,53 @@
+package gitcore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadShallow reads the shallow file, which lists the boundary commits of a shallow clone.
+// Their parents are known by name but were never fetched. Returns nil if the repository is not
+// shallow.
+// See: https://git-scm.com/docs/gitrepository-layout#Documentation/gitrepository-layout.txt-shallow
+func (r *Repository) loadShallow() (map[Hash]bool, error) {
+	file, err := os.Open(filepath.Join(r.commonDir, "shallow"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	shallow := make(map[Hash]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		id, err := NewHash(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shallow entry %q: %w", line, err)
+		}
+		shallow[id] = true
+	}
+
+	return shallow, scanner.Err()
+}
+
+// IsShallow reports whether the repository is a shallow clone with truncated history.
+func (r *Repository) IsShallow() bool {
+	return len(r.shallow) > 0
+}
+
+// ShallowCommits returns the boundary commits of a shallow clone, below which history is missing.
+func (r *Repository) ShallowCommits() []Hash {
+	result := make([]Hash, 0, len(r.shallow))
+	for id := range r.shallow {
+		result = append(result, id)
+	}
+	return result
+}
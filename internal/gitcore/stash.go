@@ -0,0 +1,94 @@
+package gitcore
+
+import (
+	"fmt"
+	"log"
+)
+
+// stashRef is the ref pointing at the most recent stash. Older stashes live only in its reflog.
+const stashRef = "refs/stash"
+
+// loadStashRef sets aside refs/stash. The stash ref is removed from the ordinary refs, since its
+// index and untracked parents are bookkeeping rather than history.
+func (r *Repository) loadStashRef() {
+	if hash, found := r.refs[stashRef]; found {
+		delete(r.refs, stashRef)
+		r.stashHead = hash
+	}
+}
+
+// loadStashes reads every stash entry, newest first, and loads the history each was made on.
+// It assumes that refs and reflogs have already been loaded.
+// See: https://git-scm.com/docs/git-stash#_discussion
+func (r *Repository) loadStashes(visited map[Hash]bool) {
+	if r.stashHead == "" {
+		return
+	}
+
+	ids := []Hash{r.stashHead}
+	if entries := r.reflogs[stashRef]; len(entries) > 0 {
+		ids = ids[:0]
+		for i := len(entries) - 1; i >= 0; i-- {
+			ids = append(ids, entries[i].New)
+		}
+	}
+
+	for n, id := range ids {
+		stash, err := r.readStash(id, fmt.Sprintf("stash@{%d}", n))
+		if err != nil {
+			// Log the error but continue with other potentially valid stashes.
+			log.Printf("error reading stash %s: %v", id.Short(), err)
+			continue
+		}
+		r.stashes = append(r.stashes, stash)
+		r.traverseObjects(stash.Base, visited)
+	}
+}
+
+// readStash reads a stash commit and checks its shape. A stash records the working tree as a
+// commit whose first parent is the commit HEAD pointed at, whose second parent records the index
+// (itself a child of that same commit), and whose optional third parent records untracked files.
+func (r *Repository) readStash(id Hash, name string) (*Stash, error) {
+	commit, err := r.Commit(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(commit.Parents) < 2 || len(commit.Parents) > 3 {
+		return nil, fmt.Errorf("stash commit has %d parents", len(commit.Parents))
+	}
+
+	index, err := r.Commit(commit.Parents[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index commit: %w", err)
+	}
+	if len(index.Parents) != 1 || index.Parents[0] != commit.Parents[0] {
+		return nil, fmt.Errorf("index commit %s is not based on %s", index.ID.Short(), commit.Parents[0].Short())
+	}
+
+	stash := &Stash{
+		ID:      commit.ID,
+		Name:    name,
+		Base:    commit.Parents[0],
+		Index:   index.ID,
+		Author:  commit.Author,
+		Message: commit.Message,
+	}
+	if len(commit.Parents) == 3 {
+		stash.Untracked = commit.Parents[2]
+	}
+	return stash, nil
+}
+
+// Stashes returns all stash entries, newest (stash@{0}) first.
+func (r *Repository) Stashes() []*Stash {
+	return r.stashes
+}
+
+// stashesByID indexes stashes by the hash of their stash commit.
+func stashesByID(stashes []*Stash) map[Hash]*Stash {
+	result := make(map[Hash]*Stash, len(stashes))
+	for _, stash := range stashes {
+		result[stash.ID] = stash
+	}
+	return result
+}
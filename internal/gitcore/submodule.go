@@ -0,0 +1,157 @@
+package gitcore
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rybkr/gitvista/internal/gitcore/config"
+)
+
+// gitmodulesFile is the file at the root of the tree that maps submodule names to their paths.
+// See: https://git-scm.com/docs/gitmodules
+const gitmodulesFile = ".gitmodules"
+
+// Submodule is a repository nested in the superproject: declared by name in .gitmodules and
+// recorded in the tree as a gitlink, an entry of mode 160000 naming a commit of the submodule.
+// See: https://git-scm.com/docs/gitsubmodules
+type Submodule struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	URL    string `json:"url,omitempty"`
+	Branch string `json:"branch,omitempty"`
+	// Commit is the submodule commit pinned by the gitlink at Path, empty if the tree has none.
+	Commit Hash `json:"commit,omitempty"`
+	// Initialized marks a submodule whose repository has been cloned, so that it can be opened.
+	Initialized bool `json:"initialized,omitempty"`
+}
+
+// loadSubmodules reads the submodules of the commit at HEAD.
+// It assumes that HEAD has already been resolved.
+func (r *Repository) loadSubmodules() {
+	r.submodules = nil
+	if r.head == "" {
+		return
+	}
+
+	submodules, err := r.CommitSubmodules(r.head)
+	if err != nil {
+		log.Printf("error reading submodules: %v", err)
+		return
+	}
+	r.submodules = submodules
+}
+
+// CommitSubmodules returns the submodules declared in a commit's .gitmodules, each with the
+// commit its gitlink pins in that commit's tree, sorted by path.
+func (r *Repository) CommitSubmodules(id Hash) ([]*Submodule, error) {
+	commit, err := r.commit(id)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, found, err := r.findTreeEntry(commit.Tree, gitmodulesFile)
+	if err != nil {
+		return nil, err
+	}
+	if !found || entry.Kind != BlobObject {
+		return nil, nil
+	}
+	content, _, err := r.readBlobContent(entry.ID)
+	if err != nil {
+		return nil, err
+	}
+	gitmodules, err := config.Parse(content, gitmodulesFile, config.ScopeLocal)
+	if err != nil {
+		return nil, err
+	}
+
+	var submodules []*Submodule
+	for _, name := range gitmodules.Subsections("submodule") {
+		path, found := gitmodules.Get("submodule", name, "path")
+		if !found || path == "" {
+			continue
+		}
+		if !isValidSubmoduleName(name) {
+			// Like git, refuse names that would escape modules/.
+			log.Printf("ignoring submodule with invalid name %q", name)
+			continue
+		}
+
+		submodule := &Submodule{Name: name, Path: strings.Trim(path, "/")}
+		submodule.URL, _ = gitmodules.Get("submodule", name, "url")
+		submodule.Branch, _ = gitmodules.Get("submodule", name, "branch")
+
+		gitlink, found, err := r.findTreeEntry(commit.Tree, submodule.Path)
+		if err != nil {
+			return nil, err
+		}
+		if found && gitlink.Mode == ModeGitlink {
+			submodule.Commit = gitlink.ID
+		}
+		submodule.Initialized = r.submoduleGitDir(name, submodule.Path) != ""
+		submodules = append(submodules, submodule)
+	}
+
+	sort.Slice(submodules, func(i, j int) bool {
+		return submodules[i].Path < submodules[j].Path
+	})
+	return submodules, nil
+}
+
+// Submodules returns the submodules of the commit at HEAD, sorted by path.
+func (r *Repository) Submodules() []*Submodule {
+	return append([]*Submodule(nil), r.submodules...)
+}
+
+// OpenSubmodule opens the repository of an initialized submodule, given its name in .gitmodules.
+func (r *Repository) OpenSubmodule(name string) (*Repository, error) {
+	if !isValidSubmoduleName(name) {
+		return nil, fmt.Errorf("invalid submodule name: %q", name)
+	}
+
+	var path string
+	for _, submodule := range r.submodules {
+		if submodule.Name == name {
+			path = submodule.Path
+		}
+	}
+	gitDir := r.submoduleGitDir(name, path)
+	if gitDir == "" {
+		return nil, fmt.Errorf("submodule %s is not initialized", name)
+	}
+	return NewRepositoryWithOptions(gitDir, Options{NoReplaceObjects: r.options.NoReplaceObjects})
+}
+
+// submoduleGitDir locates the git directory of a submodule: modules/<name> in the common directory,
+// where git clones submodules, or a .git directory embedded in an older checkout at path.
+// It returns an empty string for a submodule that has not been initialized.
+func (r *Repository) submoduleGitDir(name, path string) string {
+	gitDir := filepath.Join(r.commonDir, "modules", filepath.FromSlash(name))
+	if isGitDirectory(gitDir) {
+		return gitDir
+	}
+	if path != "" && !r.IsBare() {
+		gitDir = filepath.Join(r.workDir, filepath.FromSlash(path), ".git")
+		if isGitDirectory(gitDir) {
+			return gitDir
+		}
+	}
+	return ""
+}
+
+// isValidSubmoduleName reports whether a submodule name is safe to use as a path under modules/.
+// Git rejects names that are empty or contain a ".." component.
+func isValidSubmoduleName(name string) bool {
+	if name == "" || filepath.IsAbs(name) {
+		return false
+	}
+	for _, component := range strings.FieldsFunc(name, func(c rune) bool { return c == '/' || c == '\\' }) {
+		if component == ".." {
+			return false
+		}
+	}
+	return true
+}
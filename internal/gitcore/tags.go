@@ -0,0 +1,100 @@
+package gitcore
+
+import (
+	"log"
+	"sort"
+	"strings"
+)
+
+// tagRefPrefix is the namespace of tags.
+const tagRefPrefix = "refs/tags/"
+
+// TagRef is a tag as listed by git tag: a ref under refs/tags/ that points either directly at an
+// object (a lightweight tag) or at an annotated tag object. Target is the object the tag
+// ultimately names once every tag object in a tag-of-tag chain has been peeled, and Tag is the
+// outermost tag object of an annotated tag.
+type TagRef struct {
+	Name      string `json:"name"`
+	Hash      Hash   `json:"hash"`
+	Annotated bool   `json:"annotated"`
+	Target    Hash   `json:"target"`
+	Tag       *Tag   `json:"tag,omitempty"`
+}
+
+// loadTags resolves every tag ref to its fully peeled target, preferring the peel data recorded
+// in packed-refs over reading tag objects. It assumes that all objects have already been loaded.
+func (r *Repository) loadTags() {
+	tagObjects := make(map[Hash]*Tag, len(r.tags))
+	for _, tag := range r.tags {
+		tagObjects[tag.ID] = tag
+	}
+
+	r.tagRefs = make(map[string]*TagRef)
+	for refName, hash := range r.refs {
+		name, found := strings.CutPrefix(refName, tagRefPrefix)
+		if !found {
+			continue
+		}
+		tag := &TagRef{Name: name, Hash: hash, Target: hash}
+		r.peelTag(tag, tagObjects)
+		r.tagRefs[name] = tag
+	}
+}
+
+// peelTag fills in whether a tag is annotated and what it ultimately points to.
+func (r *Repository) peelTag(tag *TagRef, tagObjects map[Hash]*Tag) {
+	if _, found := r.commitIndex[tag.Hash]; found {
+		return
+	}
+	if target, found := r.peeledTags[tag.Hash]; found {
+		tag.Annotated = true
+		tag.Target = target
+		tag.Tag = tagObjects[tag.Hash]
+		return
+	}
+
+	for id := tag.Hash; ; {
+		object, found := tagObjects[id]
+		if !found {
+			read, err := r.readObject(id)
+			if err != nil {
+				// Lightweight tags may name blobs, which are not parsed; the tag then names its target.
+				if tag.Annotated {
+					log.Printf("error peeling tag %s: %v", tag.Name, err)
+				}
+				return
+			}
+			if object, found = read.(*Tag); !found {
+				return
+			}
+		}
+
+		if !tag.Annotated {
+			tag.Annotated = true
+			tag.Tag = object
+		}
+		tag.Target = object.Object
+		if object.ObjType != TagObject {
+			return
+		}
+		id = object.Object
+	}
+}
+
+// Tags returns every tag, lightweight and annotated, sorted by name.
+func (r *Repository) Tags() []*TagRef {
+	tags := make([]*TagRef, 0, len(r.tagRefs))
+	for _, tag := range r.tagRefs {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	return tags
+}
+
+// Tag returns the tag with the given short name, e.g. "v1.0".
+func (r *Repository) Tag(name string) (*TagRef, bool) {
+	tag, found := r.tagRefs[name]
+	return tag, found
+}
@@ -0,0 +1,221 @@
+//go:build ignore
+
+// genreftable writes the reftable stack under testdata/reftable-repo/reftable.
+//
+// Git only writes reftables from version 2.45 on. This program lays the tables out the way git's
+// writer does by default: format version 1, 4096-byte blocks, a restart point every 16 records,
+// ref blocks padded to the block size, log blocks deflated and unpadded, and no index or object
+// blocks, which git omits for tables this small.
+// See: https://git-scm.com/docs/reftable
+//
+// Run from internal/gitcore with: go run testdata/genreftable.go
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	blockSize       = 4096
+	restartInterval = 16
+	headerSize      = 24
+)
+
+const (
+	commitA = "1111111111111111111111111111111111111111"
+	commitB = "2222222222222222222222222222222222222222"
+	tagV1   = "3333333333333333333333333333333333333333"
+	zero    = "0000000000000000000000000000000000000000"
+)
+
+type record struct {
+	key       []byte
+	valueType byte
+	value     []byte
+}
+
+type logEntry struct {
+	ref         string
+	updateIndex uint64
+	oldID       string
+	newID       string
+	time        uint64
+	tz          int16 // As git stores it: the decimal digits of +HHMM, e.g. 200 for +0200.
+	message     string
+}
+
+func main() {
+	dir := filepath.Join("testdata", "reftable-repo", "reftable")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Fatal(err)
+	}
+
+	first := writeTable(1, 2,
+		[]record{
+			symref("HEAD", 0, "refs/heads/main"),
+			ref("refs/heads/main", 0, commitA),
+			ref("refs/heads/old", 1, commitA),
+			symref("refs/remotes/origin/HEAD", 1, "refs/remotes/origin/gone"),
+			peeled("refs/tags/v1", 1, tagV1, commitA),
+		},
+		[]logEntry{
+			{"HEAD", 1, zero, commitA, 1700000000, 200, "commit (initial): one"},
+			{"refs/heads/main", 1, zero, commitA, 1700000000, 200, "commit (initial): one"},
+			{"refs/heads/old", 2, zero, commitA, 1700000100, -700, "branch: Created from main"},
+		})
+	second := writeTable(3, 3,
+		[]record{
+			ref("refs/heads/main", 0, commitB),
+			deletion("refs/heads/old", 0),
+		},
+		[]logEntry{
+			{"HEAD", 3, commitA, commitB, 1700000200, 200, "commit: two"},
+			{"refs/heads/main", 3, commitA, commitB, 1700000200, 200, "commit: two"},
+		})
+
+	names := []string{"0x000000000001-0x000000000002-00000001.ref", "0x000000000003-0x000000000003-00000002.ref"}
+	for i, table := range [][]byte{first, second} {
+		if err := os.WriteFile(filepath.Join(dir, names[i]), table, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+	list := names[0] + "\n" + names[1] + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "tables.list"), []byte(list), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func ref(name string, updateDelta uint64, id string) record {
+	return record{[]byte(name), 1, append(varint(updateDelta), hexBytes(id)...)}
+}
+
+func peeled(name string, updateDelta uint64, id, target string) record {
+	value := append(varint(updateDelta), hexBytes(id)...)
+	return record{[]byte(name), 2, append(value, hexBytes(target)...)}
+}
+
+func symref(name string, updateDelta uint64, target string) record {
+	value := append(varint(updateDelta), varint(uint64(len(target)))...)
+	return record{[]byte(name), 3, append(value, target...)}
+}
+
+func deletion(name string, updateDelta uint64) record {
+	return record{[]byte(name), 0, varint(updateDelta)}
+}
+
+// writeTable encodes one ref block and one log block between a header and a footer.
+func writeTable(minUpdate, maxUpdate uint64, refs []record, logs []logEntry) []byte {
+	header := []byte("REFT")
+	header = append(header, 1, blockSize>>16, blockSize>>8&0xff, blockSize&0xff)
+	header = binary.BigEndian.AppendUint64(header, minUpdate)
+	header = binary.BigEndian.AppendUint64(header, maxUpdate)
+
+	sort.Slice(refs, func(i, j int) bool { return bytes.Compare(refs[i].key, refs[j].key) < 0 })
+	table := append([]byte(nil), header...)
+	table = append(table, encodeBlock('r', headerSize, refs)[headerSize:]...)
+	for len(table)%blockSize != 0 {
+		table = append(table, 0)
+	}
+
+	logPosition := len(table)
+	var logRecords []record
+	for _, entry := range logs {
+		logRecords = append(logRecords, encodeLog(entry))
+	}
+	sort.Slice(logRecords, func(i, j int) bool { return bytes.Compare(logRecords[i].key, logRecords[j].key) < 0 })
+	block := encodeBlock('g', 0, logRecords)
+	var deflated bytes.Buffer
+	zw := zlib.NewWriter(&deflated)
+	zw.Write(block[4:])
+	zw.Close()
+	table = append(table, block[:4]...)
+	table = append(table, deflated.Bytes()...)
+
+	footer := append([]byte(nil), header...)
+	footer = binary.BigEndian.AppendUint64(footer, 0) // ref_index_position
+	footer = binary.BigEndian.AppendUint64(footer, 0) // obj_position << 5 | obj_id_len
+	footer = binary.BigEndian.AppendUint64(footer, 0) // obj_index_position
+	footer = binary.BigEndian.AppendUint64(footer, uint64(logPosition))
+	footer = binary.BigEndian.AppendUint64(footer, 0) // log_index_position
+	footer = binary.BigEndian.AppendUint32(footer, crc32.ChecksumIEEE(footer))
+	return append(table, footer...)
+}
+
+// encodeLog builds a log record, keyed so that newer updates of a ref sort first.
+func encodeLog(entry logEntry) record {
+	key := append([]byte(entry.ref), 0)
+	key = binary.BigEndian.AppendUint64(key, ^entry.updateIndex)
+
+	value := append(hexBytes(entry.oldID), hexBytes(entry.newID)...)
+	value = appendString(value, "A U Thor")
+	value = appendString(value, "author@example.com")
+	value = append(value, varint(entry.time)...)
+	value = binary.BigEndian.AppendUint16(value, uint16(entry.tz))
+	value = appendString(value, entry.message+"\n")
+	return record{key, 1, value}
+}
+
+// encodeBlock prefix-compresses records into a block whose offsets count from the start of the
+// file when headerOff is the file header's size, as for the first block.
+func encodeBlock(blockType byte, headerOff int, records []record) []byte {
+	block := make([]byte, headerOff+4)
+	var restarts []int
+	var last []byte
+	for i, rec := range records {
+		prefix := 0
+		if i%restartInterval == 0 {
+			restarts = append(restarts, len(block))
+		} else {
+			for prefix < len(last) && prefix < len(rec.key) && last[prefix] == rec.key[prefix] {
+				prefix++
+			}
+		}
+		block = append(block, varint(uint64(prefix))...)
+		block = append(block, varint(uint64(len(rec.key)-prefix)<<3|uint64(rec.valueType))...)
+		block = append(block, rec.key[prefix:]...)
+		block = append(block, rec.value...)
+		last = rec.key
+	}
+	for _, offset := range restarts {
+		block = append(block, byte(offset>>16), byte(offset>>8), byte(offset))
+	}
+	block = binary.BigEndian.AppendUint16(block, uint16(len(restarts)))
+
+	block[headerOff] = blockType
+	length := len(block)
+	block[headerOff+1], block[headerOff+2], block[headerOff+3] = byte(length>>16), byte(length>>8), byte(length)
+	return block
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(append(b, varint(uint64(len(s)))...), s...)
+}
+
+// varint encodes an integer in the format shared by reftables and OFS_DELTA offsets.
+func varint(v uint64) []byte {
+	var buf [10]byte
+	pos := len(buf) - 1
+	buf[pos] = byte(v & 0x7f)
+	for v >>= 7; v > 0; v >>= 7 {
+		v--
+		pos--
+		buf[pos] = 0x80 | byte(v&0x7f)
+	}
+	return append([]byte(nil), buf[pos:]...)
+}
+
+func hexBytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,230 @@
+package gitcore
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// ChangeType denotes how a path differs between two trees.
+type ChangeType string
+
+const (
+	ChangeAdded       ChangeType = "added"
+	ChangeModified    ChangeType = "modified"
+	ChangeDeleted     ChangeType = "deleted"
+	ChangeTypeChanged ChangeType = "typechange"
+	ChangeRenamed     ChangeType = "renamed"
+	ChangeCopied      ChangeType = "copied"
+)
+
+// FileChange describes a single path that differs between two trees.
+// Hashes and modes are empty on the side where the path does not exist.
+// For renames and copies, OldPath names the source and Similarity is the percentage of content
+// shared between the two sides.
+type FileChange struct {
+	Type       ChangeType `json:"type"`
+	Path       string     `json:"path"`
+	OldPath    string     `json:"oldPath,omitempty"`
+	OldMode    FileMode   `json:"oldMode"`
+	NewMode    FileMode   `json:"newMode"`
+	OldHash    Hash       `json:"oldHash"`
+	NewHash    Hash       `json:"newHash"`
+	Similarity int        `json:"similarity,omitempty"`
+}
+
+// CommitChanges returns the paths changed by a commit relative to its first parent, with renames
+// and copies detected according to opts.
+// Root commits are compared against the empty tree.
+func (r *Repository) CommitChanges(id Hash, opts RenameOptions) ([]FileChange, error) {
+	commit, err := r.commit(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var parentTree Hash
+	if len(commit.Parents) > 0 {
+		parent, err := r.commit(commit.Parents[0])
+		if err != nil {
+			return nil, err
+		}
+		parentTree = parent.Tree
+	}
+
+	changes, err := r.DiffTrees(parentTree, commit.Tree)
+	if err != nil {
+		return nil, err
+	}
+	return r.DetectRenames(changes, opts)
+}
+
+// DiffTrees recursively compares two trees and returns every changed file, sorted by path.
+// An empty hash on either side stands for the empty tree.
+// Like git, a path that switches between a file and a directory is reported as a deletion
+// followed by additions rather than as a type change.
+func (r *Repository) DiffTrees(oldTree, newTree Hash) ([]FileChange, error) {
+	var changes []FileChange
+	if err := r.diffTrees(oldTree, newTree, "", &changes); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// diffTrees appends the differences between two trees rooted at prefix to changes.
+func (r *Repository) diffTrees(oldTree, newTree Hash, prefix string, changes *[]FileChange) error {
+	if oldTree == newTree {
+		return nil
+	}
+
+	oldEntries, err := r.treeEntries(oldTree)
+	if err != nil {
+		return err
+	}
+	newEntries, err := r.treeEntries(newTree)
+	if err != nil {
+		return err
+	}
+
+	for name, newEntry := range newEntries {
+		entryPath := path.Join(prefix, name)
+		oldEntry, found := oldEntries[name]
+
+		switch {
+		case !found:
+			if err := r.diffEntry(nil, &newEntry, entryPath, changes); err != nil {
+				return err
+			}
+		case oldEntry.ID == newEntry.ID && oldEntry.Mode == newEntry.Mode:
+			continue
+		case oldEntry.Kind == TreeObject && newEntry.Kind == TreeObject:
+			if err := r.diffTrees(oldEntry.ID, newEntry.ID, entryPath, changes); err != nil {
+				return err
+			}
+		case oldEntry.Kind == TreeObject || newEntry.Kind == TreeObject:
+			if err := r.diffEntry(&oldEntry, nil, entryPath, changes); err != nil {
+				return err
+			}
+			if err := r.diffEntry(nil, &newEntry, entryPath, changes); err != nil {
+				return err
+			}
+		default:
+			if err := r.diffEntry(&oldEntry, &newEntry, entryPath, changes); err != nil {
+				return err
+			}
+		}
+	}
+
+	for name, oldEntry := range oldEntries {
+		if _, found := newEntries[name]; !found {
+			if err := r.diffEntry(&oldEntry, nil, path.Join(prefix, name), changes); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// diffEntry records the change between two entries at the same path, either of which may be nil.
+// Added or deleted directories are expanded into one change per file they contain.
+func (r *Repository) diffEntry(oldEntry, newEntry *TreeEntry, entryPath string, changes *[]FileChange) error {
+	switch {
+	case oldEntry == nil && newEntry.Kind == TreeObject:
+		return r.diffTrees("", newEntry.ID, entryPath, changes)
+	case newEntry == nil && oldEntry.Kind == TreeObject:
+		return r.diffTrees(oldEntry.ID, "", entryPath, changes)
+	}
+
+	change := FileChange{Path: entryPath}
+	if oldEntry != nil {
+		change.OldMode, change.OldHash = oldEntry.Mode, oldEntry.ID
+	}
+	if newEntry != nil {
+		change.NewMode, change.NewHash = newEntry.Mode, newEntry.ID
+	}
+
+	switch {
+	case oldEntry == nil:
+		change.Type = ChangeAdded
+	case newEntry == nil:
+		change.Type = ChangeDeleted
+	case oldEntry.Mode.format() != newEntry.Mode.format():
+		change.Type = ChangeTypeChanged
+	default:
+		change.Type = ChangeModified
+	}
+
+	*changes = append(*changes, change)
+	return nil
+}
+
+// treeEntries reads a tree and indexes its entries by name.
+// An empty hash yields no entries.
+func (r *Repository) treeEntries(id Hash) (map[string]TreeEntry, error) {
+	entries := make(map[string]TreeEntry)
+	if id == "" {
+		return entries, nil
+	}
+
+	tree, err := r.Tree(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range tree.Entries {
+		entries[entry.Name] = entry
+	}
+	return entries, nil
+}
+
+// commit returns the commit with the given hash, reading it from the object store if it is not
+// part of the loaded history.
+func (r *Repository) commit(id Hash) (*Commit, error) {
+	if commit, found := r.commitIndex[id]; found {
+		return commit, nil
+	}
+
+	object, err := r.readObject(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", id, err)
+	}
+
+	commit, ok := object.(*Commit)
+	if !ok {
+		return nil, fmt.Errorf("object %s is not a commit", id)
+	}
+	return commit, nil
+}
+
+// FilePatch pairs a changed path with its line-level diff.
+// Diff is nil for changes that have no textual content, such as gitlinks.
+type FilePatch struct {
+	FileChange
+	Diff *BlobDiff `json:"diff"`
+}
+
+// CommitPatch returns the line-level diff of every path changed by a commit relative to its
+// first parent.
+func (r *Repository) CommitPatch(id Hash, opts DiffOptions) ([]FilePatch, error) {
+	changes, err := r.CommitChanges(id, opts.Renames)
+	if err != nil {
+		return nil, err
+	}
+
+	patches := make([]FilePatch, 0, len(changes))
+	for _, change := range changes {
+		patch := FilePatch{FileChange: change}
+		if change.OldMode.Kind() == BlobObject && change.NewMode.Kind() == BlobObject {
+			diff, err := r.DiffBlobs(change.OldHash, change.NewHash, opts)
+			if err != nil {
+				return nil, err
+			}
+			patch.Diff = diff
+		}
+		patches = append(patches, patch)
+	}
+	return patches, nil
+}
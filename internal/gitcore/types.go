@@ -1,9 +1,13 @@
 package gitcore
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"regexp"
+	"runtime"
 	"strings"
 	"time"
 )
@@ -12,12 +16,12 @@ var (
 	signatureRe = regexp.MustCompile("[<>]")
 )
 
-// Hash represents a Git object hash.
+// Hash represents a Git object hash, either SHA-1 or SHA-256.
 type Hash string
 
 // NewHash creates a Hash from a hexadecimal string, validating its format.
 func NewHash(s string) (Hash, error) {
-	if len(s) != 40 {
+	if len(s) != SHA1.HexSize() && len(s) != SHA256.HexSize() {
 		return "", fmt.Errorf("invalid hash length: %d", len(s))
 	}
 	if _, err := hex.DecodeString(s); err != nil {
@@ -26,9 +30,31 @@ func NewHash(s string) (Hash, error) {
 	return Hash(s), nil
 }
 
-// NewHashFromBytes creates a Hash from a 20-byte array.
-func NewHashFromBytes(b [20]byte) (Hash, error) {
-	return NewHash(hex.EncodeToString(b[:]))
+// NewHashFromBytes creates a Hash from a raw 20-byte SHA-1 or 32-byte SHA-256 digest.
+func NewHashFromBytes(b []byte) (Hash, error) {
+	return NewHash(hex.EncodeToString(b))
+}
+
+// ObjectFormat identifies the hash algorithm a repository uses to name its objects.
+// See: https://git-scm.com/docs/hash-function-transition
+type ObjectFormat string
+
+const (
+	SHA1   ObjectFormat = "sha1"
+	SHA256 ObjectFormat = "sha256"
+)
+
+// Size returns the length in bytes of a raw object name in this format.
+func (f ObjectFormat) Size() int {
+	if f == SHA256 {
+		return 32
+	}
+	return 20
+}
+
+// HexSize returns the length of a hexadecimal object name in this format.
+func (f ObjectFormat) HexSize() int {
+	return 2 * f.Size()
 }
 
 // Short returns the truncated representation of a Hash.
@@ -48,6 +74,8 @@ type ObjectType int
 const (
 	NoneObject   ObjectType = 0
 	CommitObject ObjectType = 1
+	TreeObject   ObjectType = 2
+	BlobObject   ObjectType = 3
 	TagObject    ObjectType = 4
 )
 
@@ -56,6 +84,10 @@ func StrToObjectType(s string) ObjectType {
 	switch s {
 	case "commit":
 		return CommitObject
+	case "tree":
+		return TreeObject
+	case "blob":
+		return BlobObject
 	case "tag":
 		return TagObject
 	default:
@@ -64,13 +96,22 @@ func StrToObjectType(s string) ObjectType {
 }
 
 // Commit represents a Git commit object with its metadata and relationships.
+// Commits loaded from the commit-graph are Partial: they carry their tree, parents, commit time
+// and generation number, but no author, committer identity or message.
+// Shallow commits sit on the boundary of a shallow clone: their parents are listed but missing.
+// Notes maps the short name of each notes ref (e.g. "commits") to the note it attaches.
 type Commit struct {
-	ID        Hash      `json:"hash"`
-	Tree      Hash      `json:"tree"`
-	Parents   []Hash    `json:"parents"`
-	Author    Signature `json:"author"`
-	Committer Signature `json:"committer"`
-	Message   string    `json:"message"`
+	ID         Hash      `json:"hash"`
+	Tree       Hash      `json:"tree"`
+	Parents    []Hash    `json:"parents"`
+	Author     Signature `json:"author"`
+	Committer  Signature `json:"committer"`
+	Message    string    `json:"message"`
+	Generation uint64    `json:"generation,omitempty"`
+	Partial    bool      `json:"partial,omitempty"`
+	Shallow    bool      `json:"shallow,omitempty"`
+
+	Notes map[string]string `json:"notes,omitempty"`
 }
 
 // Type returns the object type for a Commit.
@@ -93,6 +134,101 @@ func (t *Tag) Type() ObjectType {
 	return TagObject
 }
 
+// Tree represents a Git tree object, listing the entries of a single directory.
+type Tree struct {
+	ID      Hash        `json:"hash"`
+	Entries []TreeEntry `json:"entries"`
+}
+
+// Type returns the object type for a Tree.
+func (t *Tree) Type() ObjectType {
+	return TreeObject
+}
+
+// Entry returns the entry with the given name and true, or false if no such entry exists.
+func (t *Tree) Entry(name string) (TreeEntry, bool) {
+	for _, entry := range t.Entries {
+		if entry.Name == name {
+			return entry, true
+		}
+	}
+	return TreeEntry{}, false
+}
+
+// TreeEntry represents a single entry in a Tree: a file, a subdirectory, a symlink, or a gitlink.
+type TreeEntry struct {
+	ID   Hash       `json:"hash"`
+	Name string     `json:"name"`
+	Mode FileMode   `json:"mode"`
+	Kind ObjectType `json:"kind"`
+}
+
+// FileMode is the mode of a tree entry as recorded by Git.
+// See: https://git-scm.com/docs/git-fast-import#_filemodify
+type FileMode uint32
+
+const (
+	ModeTree       FileMode = 0o040000
+	ModeRegular    FileMode = 0o100644
+	ModeExecutable FileMode = 0o100755
+	ModeSymlink    FileMode = 0o120000
+	ModeGitlink    FileMode = 0o160000
+)
+
+// Kind returns the type of object an entry with this mode refers to.
+// Gitlinks refer to commits in another repository (submodules).
+func (m FileMode) Kind() ObjectType {
+	switch m {
+	case ModeTree:
+		return TreeObject
+	case ModeGitlink:
+		return CommitObject
+	default:
+		return BlobObject
+	}
+}
+
+// format returns the file format bits of the mode, ignoring permissions.
+// A regular file and an executable share a format; a symlink does not.
+func (m FileMode) format() FileMode {
+	return m & 0o170000
+}
+
+// String returns the mode in Git's canonical six-digit octal form.
+func (m FileMode) String() string {
+	return fmt.Sprintf("%06o", uint32(m))
+}
+
+// MarshalJSON encodes the mode as its octal string, as shown by git ls-tree.
+func (m FileMode) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + m.String() + `"`), nil
+}
+
+// Blob represents a Git blob object whose content is streamed on demand.
+// Callers must Close a Blob once they are done reading from it.
+type Blob struct {
+	ID     Hash  `json:"hash"`
+	Size   int64 `json:"size"`
+	Binary bool  `json:"binary"`
+
+	content io.ReadCloser
+}
+
+// Type returns the object type for a Blob.
+func (b *Blob) Type() ObjectType {
+	return BlobObject
+}
+
+// Read reads decompressed blob content into p.
+func (b *Blob) Read(p []byte) (int, error) {
+	return b.content.Read(p)
+}
+
+// Close releases any file handles or decompressors held by the Blob.
+func (b *Blob) Close() error {
+	return b.content.Close()
+}
+
 // Signature represents a Git author or committer signature with name, email, and timestamp.
 type Signature struct {
 	Name  string    `json:"name"`
@@ -100,7 +236,8 @@ type Signature struct {
 	When  time.Time `json:"when"`
 }
 
-// NewSignature parses a signature line in the format "Name <email> timestamp" and returns a Signature struct.
+// NewSignature parses a signature line in the format "Name <email> timestamp timezone" and returns a Signature struct.
+// The timezone is optional; without it the time is reported in the local zone.
 func NewSignature(signLine string) (Signature, error) {
 	parts := signatureRe.Split(signLine, -1)
 	if len(parts) != 3 {
@@ -121,28 +258,106 @@ func NewSignature(signLine string) (Signature, error) {
 		return Signature{}, fmt.Errorf("invalid signature line: invalid timestamp: %q", signLine)
 	}
 
+	when := time.Unix(unixTime, 0)
+	if len(timeFields) > 1 {
+		if location, err := parseTimezone(timeFields[1]); err == nil {
+			when = when.In(location)
+		}
+	}
+
 	return Signature{
 		Name:  name,
 		Email: email,
-		When:  time.Unix(unixTime, 0),
+		When:  when,
 	}, nil
 }
 
+// parseTimezone parses a signature's UTC offset, in the form "+hhmm" or "-hhmm".
+func parseTimezone(s string) (*time.Location, error) {
+	if len(s) != 5 || (s[0] != '+' && s[0] != '-') {
+		return nil, fmt.Errorf("invalid timezone: %q", s)
+	}
+	var hours, minutes int
+	if _, err := fmt.Sscanf(s[1:], "%02d%02d", &hours, &minutes); err != nil {
+		return nil, fmt.Errorf("invalid timezone: %q", s)
+	}
+
+	offset := hours*3600 + minutes*60
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return time.FixedZone(s, offset), nil
+}
+
 // PackIndex represents a Git pack index file that maps object hashes to their locations within pack files.
+// The index file stays memory-mapped, and objects are found through its fanout table and a binary search
+// over the sorted object names.
 type PackIndex struct {
 	path       string
 	packPath   string
 	version    uint32
 	numObjects uint32
-	fanout     [256]uint32
-	offsets    map[Hash]int64
+	hashSize   int
+
+	data    []byte // The mapping that the tables below slice into.
+	cleanup runtime.Cleanup
+
+	fanout       []byte // 256 cumulative counts by first name byte; nil once the index is closed.
+	names        []byte
+	nameStride   int
+	offsets      []byte
+	offsetStride int
+	largeOffsets []byte
 }
 
 // FindObject looks up the offset of an object in the pack file by its hash.
 // Returns the offset and true if found, otherwise returns 0 and false.
 func (p *PackIndex) FindObject(id Hash) (int64, bool) {
-	offset, found := p.offsets[id]
-	return offset, found
+	raw, err := hex.DecodeString(string(id))
+	if err != nil || len(raw) != p.hashSize {
+		return 0, false
+	}
+
+	lo, hi := uint32(0), p.numObjects
+	if p.fanout != nil {
+		if raw[0] > 0 {
+			lo = binary.BigEndian.Uint32(p.fanout[(int(raw[0])-1)*4:])
+		}
+		hi = binary.BigEndian.Uint32(p.fanout[int(raw[0])*4:])
+	}
+
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		switch cmp := bytes.Compare(p.nameAt(mid), raw); {
+		case cmp == 0:
+			return p.offsetAt(mid)
+		case cmp < 0:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, false
+}
+
+// nameAt returns the raw object name at a sorted position.
+func (p *PackIndex) nameAt(i uint32) []byte {
+	start := int(i) * p.nameStride
+	return p.names[start : start+p.hashSize]
+}
+
+// offsetAt returns the pack offset of the object at a sorted position, following the large
+// offset table, which runs up to the trailing checksums, for offsets that do not fit in 31 bits.
+func (p *PackIndex) offsetAt(i uint32) (int64, bool) {
+	offset := binary.BigEndian.Uint32(p.offsets[int(i)*p.offsetStride:])
+	if p.version == 1 || offset&0x80000000 == 0 {
+		return int64(offset), true
+	}
+	start := int(offset&0x7FFFFFFF) * 8
+	if start+8 > len(p.largeOffsets) {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(p.largeOffsets[start:])), true
 }
 
 // PackFile returns the path to the pack file associated with this index.
@@ -150,6 +365,19 @@ func (p *PackIndex) PackFile() string {
 	return p.packPath
 }
 
+// Stash is an entry of the stash: uncommitted changes set aside on top of the Base commit.
+// Index and Untracked are the commits recording the staged changes and, if stashed with
+// --include-untracked, the untracked files.
+type Stash struct {
+	ID        Hash      `json:"hash"`
+	Name      string    `json:"name"`
+	Base      Hash      `json:"base"`
+	Index     Hash      `json:"index"`
+	Untracked Hash      `json:"untracked,omitempty"`
+	Author    Signature `json:"author"`
+	Message   string    `json:"message"`
+}
+
 // RepositoryDelta represents the difference between two repositories in a digestable format.
 // This structure gets sent to the front end during live updates.
 type RepositoryDelta struct {
@@ -159,6 +387,40 @@ type RepositoryDelta struct {
 	AddedBranches   map[string]Hash `json:"addedBranches"`
 	AmendedBranches map[string]Hash `json:"amendedBranches"`
 	DeletedBranches map[string]Hash `json:"deletedBranches"`
+
+	// Remote-tracking branches are named like "origin/main".
+	AddedRemoteBranches   map[string]Hash `json:"addedRemoteBranches"`
+	AmendedRemoteBranches map[string]Hash `json:"amendedRemoteBranches"`
+	DeletedRemoteBranches map[string]Hash `json:"deletedRemoteBranches"`
+
+	// Tags are keyed by short name; a tag that is re-pointed (git tag -f) is amended.
+	AddedTags   map[string]*TagRef `json:"addedTags"`
+	AmendedTags map[string]*TagRef `json:"amendedTags"`
+	DeletedTags map[string]*TagRef `json:"deletedTags"`
+
+	// Commits that became or stopped being shallow boundaries, e.g. after git fetch --deepen.
+	AddedShallows   []Hash `json:"addedShallows"`
+	DeletedShallows []Hash `json:"deletedShallows"`
+
+	// Notes of commits present before and after the update whose notes were added, edited or
+	// removed, keyed by commit. Each value holds all of the commit's notes, and is empty once
+	// the last one is removed.
+	UpdatedNotes map[Hash]map[string]string `json:"updatedNotes"`
+
+	// Stashes are keyed by their stash commit; one whose stash@{n} name shifts is deleted and re-added.
+	AddedStashes   []*Stash `json:"addedStashes"`
+	DeletedStashes []*Stash `json:"deletedStashes"`
+
+	// Worktrees are keyed by checkout path; one whose HEAD moves or whose lock changes is amended.
+	AddedWorktrees   map[string]*Worktree `json:"addedWorktrees"`
+	AmendedWorktrees map[string]*Worktree `json:"amendedWorktrees"`
+	DeletedWorktrees map[string]*Worktree `json:"deletedWorktrees"`
+
+	// Refs outside of branches, remote-tracking branches and tags, such as refs/pull/*, keyed by
+	// full name.
+	AddedRefs   map[string]Ref `json:"addedRefs"`
+	AmendedRefs map[string]Ref `json:"amendedRefs"`
+	DeletedRefs map[string]Ref `json:"deletedRefs"`
 }
 
 // NewRepositoryDelta returns a new RepositoryDelta struct.
@@ -167,10 +429,36 @@ func NewRepositoryDelta() *RepositoryDelta {
 		AddedBranches:   make(map[string]Hash),
 		AmendedBranches: make(map[string]Hash),
 		DeletedBranches: make(map[string]Hash),
+
+		AddedRemoteBranches:   make(map[string]Hash),
+		AmendedRemoteBranches: make(map[string]Hash),
+		DeletedRemoteBranches: make(map[string]Hash),
+
+		AddedTags:   make(map[string]*TagRef),
+		AmendedTags: make(map[string]*TagRef),
+		DeletedTags: make(map[string]*TagRef),
+
+		AddedWorktrees:   make(map[string]*Worktree),
+		AmendedWorktrees: make(map[string]*Worktree),
+		DeletedWorktrees: make(map[string]*Worktree),
+
+		AddedRefs:   make(map[string]Ref),
+		AmendedRefs: make(map[string]Ref),
+		DeletedRefs: make(map[string]Ref),
+
+		UpdatedNotes: make(map[Hash]map[string]string),
 	}
 }
 
 // IsEmpty reports whether a RepositoryDelta represents no difference.
 func (d *RepositoryDelta) IsEmpty() bool {
-	return len(d.AddedCommits) == 0 && len(d.DeletedCommits) == 0
+	return len(d.AddedCommits) == 0 && len(d.DeletedCommits) == 0 &&
+		len(d.AddedBranches) == 0 && len(d.AmendedBranches) == 0 && len(d.DeletedBranches) == 0 &&
+		len(d.AddedRemoteBranches) == 0 && len(d.AmendedRemoteBranches) == 0 &&
+		len(d.DeletedRemoteBranches) == 0 &&
+		len(d.AddedTags) == 0 && len(d.AmendedTags) == 0 && len(d.DeletedTags) == 0 &&
+		len(d.AddedShallows) == 0 && len(d.DeletedShallows) == 0 && len(d.UpdatedNotes) == 0 &&
+		len(d.AddedStashes) == 0 && len(d.DeletedStashes) == 0 &&
+		len(d.AddedWorktrees) == 0 && len(d.AmendedWorktrees) == 0 && len(d.DeletedWorktrees) == 0 &&
+		len(d.AddedRefs) == 0 && len(d.AmendedRefs) == 0 && len(d.DeletedRefs) == 0
 }
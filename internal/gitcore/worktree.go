@@ -0,0 +1,230 @@
+package gitcore
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Worktree is a checkout of the repository: the main worktree or one added with git worktree add.
+// Each worktree has its own HEAD. Branch is the full name of the branch HEAD points to, and Head
+// is empty while that branch is unborn.
+// See: https://git-scm.com/docs/git-worktree
+type Worktree struct {
+	// Name is the worktree's directory under worktrees/, or empty for the main worktree.
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Head     Hash   `json:"head,omitempty"`
+	Branch   string `json:"branch,omitempty"`
+	Detached bool   `json:"detached,omitempty"`
+	Main     bool   `json:"main,omitempty"`
+	// Current marks the worktree the repository was opened from.
+	Current bool `json:"current,omitempty"`
+	Locked  bool `json:"locked,omitempty"`
+	// Prunable marks a linked worktree whose checkout no longer exists.
+	Prunable bool `json:"prunable,omitempty"`
+}
+
+// perWorktreeRefPrefixes are the ref namespaces that each worktree keeps for itself, in its own
+// git directory, rather than sharing through the common directory.
+// See: https://git-scm.com/docs/git-worktree#_refs
+var perWorktreeRefPrefixes = []string{"refs/bisect/", "refs/worktree/", "refs/rewritten/"}
+
+// isPerWorktreeRef reports whether a ref belongs to a single worktree.
+func isPerWorktreeRef(name string) bool {
+	for _, prefix := range perWorktreeRefPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isGitDirectory reports whether path looks like a git directory itself, rather than a working
+// directory: a bare repository, a .git directory, or the private directory of a linked worktree.
+func isGitDirectory(path string) bool {
+	if _, err := os.Stat(filepath.Join(path, "HEAD")); err != nil {
+		return false
+	}
+	for _, marker := range []string{"commondir", "objects"} {
+		if _, err := os.Stat(filepath.Join(path, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// readCommonDir returns the directory holding the state shared by all worktrees: the path in the
+// git directory's commondir file, resolved against the git directory, or the git directory itself.
+// See: https://git-scm.com/docs/gitrepository-layout#Documentation/gitrepository-layout.txt-commondir
+func readCommonDir(gitDir string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if os.IsNotExist(err) {
+		return gitDir, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	commonDir := strings.TrimSpace(string(content))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return filepath.Clean(commonDir), nil
+}
+
+// linkedWorktreePath returns the checkout of a linked worktree, recorded in the gitdir file of its
+// private directory as the path of the checkout's .git file.
+func linkedWorktreePath(adminDir string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(adminDir, "gitdir"))
+	if err != nil {
+		return "", err
+	}
+	gitFile := strings.TrimSpace(string(content))
+	if !filepath.IsAbs(gitFile) {
+		gitFile = filepath.Join(adminDir, gitFile)
+	}
+	return filepath.Dir(filepath.Clean(gitFile)), nil
+}
+
+// loadWorktrees enumerates the main worktree and every linked worktree under worktrees/, resolving
+// each one's HEAD against the loaded refs. It assumes that refs have already been loaded.
+func (r *Repository) loadWorktrees() {
+	r.worktrees = nil
+
+	if !r.IsBare() {
+		path, found := r.configuredWorktree()
+		if !found {
+			path = filepath.Dir(r.commonDir)
+		}
+		if wt, err := r.readWorktree(r.commonDir, "", path); err != nil {
+			log.Printf("error reading main worktree: %v", err)
+		} else {
+			wt.Main = true
+			r.worktrees = append(r.worktrees, wt)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(r.commonDir, "worktrees"))
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("error listing worktrees: %v", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		adminDir := filepath.Join(r.commonDir, "worktrees", entry.Name())
+		path, err := linkedWorktreePath(adminDir)
+		if err != nil {
+			// Log the error but continue with other potentially valid worktrees.
+			log.Printf("error reading worktree %s: %v", entry.Name(), err)
+			continue
+		}
+		wt, err := r.readWorktree(adminDir, entry.Name(), path)
+		if err != nil {
+			log.Printf("error reading worktree %s: %v", entry.Name(), err)
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(adminDir, "locked")); err == nil {
+			wt.Locked = true
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			wt.Prunable = true
+		}
+		r.worktrees = append(r.worktrees, wt)
+	}
+}
+
+// configuredWorktree returns the main worktree set by core.worktree, resolved against the common
+// directory. Submodules absorbed into modules/ rely on it to find their checkout.
+// See: https://git-scm.com/docs/git-config#Documentation/git-config.txt-coreworktree
+func (r *Repository) configuredWorktree() (string, bool) {
+	configured, found := r.config.Get("core", "", "worktree")
+	if !found || configured == "" {
+		return "", false
+	}
+	if !filepath.IsAbs(configured) {
+		configured = filepath.Join(r.commonDir, configured)
+	}
+	return filepath.Clean(configured), true
+}
+
+// readWorktree reads the HEAD of the worktree whose private git directory is adminDir.
+func (r *Repository) readWorktree(adminDir, name, path string) (*Worktree, error) {
+	ref, id, err := r.readWorktreeHead(adminDir)
+	if err != nil {
+		return nil, err
+	}
+
+	wt := &Worktree{
+		Name:    name,
+		Path:    path,
+		Head:    id,
+		Branch:  ref,
+		Current: filepath.Clean(adminDir) == filepath.Clean(r.gitDir),
+	}
+	if ref == "" {
+		wt.Detached = true
+	} else {
+		wt.Head = r.refs[ref] // Empty while the branch is unborn.
+	}
+	return wt, nil
+}
+
+// readWorktreeHead reads the HEAD kept in a worktree's private git directory, returning the ref
+// it points to, or the commit it holds when detached.
+func (r *Repository) readWorktreeHead(adminDir string) (string, Hash, error) {
+	if r.refStorage == RefStorageReftable {
+		tables, err := readReftableStack(filepath.Join(adminDir, "reftable"), r.objectFormat)
+		if err != nil {
+			return "", "", err
+		}
+		var head *reftableRef
+		for _, table := range tables {
+			for i := range table.refs {
+				if table.refs[i].name == "HEAD" {
+					head = &table.refs[i]
+				}
+			}
+		}
+		if head == nil || head.deleted {
+			return "", "", fmt.Errorf("no HEAD in reftable of %s", adminDir)
+		}
+		return head.target, head.id, nil
+	}
+
+	content, err := os.ReadFile(filepath.Join(adminDir, "HEAD"))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	line := strings.TrimSpace(string(content))
+	if ref, found := strings.CutPrefix(line, "ref: "); found {
+		return ref, "", nil
+	}
+	id, err := NewHash(line)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid HEAD: %w", err)
+	}
+	return "", id, nil
+}
+
+// Worktrees returns the main worktree, unless the repository is bare, followed by every linked
+// worktree sorted by name.
+func (r *Repository) Worktrees() []*Worktree {
+	worktrees := append([]*Worktree(nil), r.worktrees...)
+	sort.SliceStable(worktrees, func(i, j int) bool {
+		return worktrees[i].Main || (!worktrees[j].Main && worktrees[i].Name < worktrees[j].Name)
+	})
+	return worktrees
+}
+
+// worktreesByPath indexes worktrees by their checkout path.
+func worktreesByPath(worktrees []*Worktree) map[string]*Worktree {
+	result := make(map[string]*Worktree, len(worktrees))
+	for _, wt := range worktrees {
+		result[wt.Path] = wt
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+package gitcore
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runGit runs git in dir, isolated from the user's configuration, and returns its trimmed output.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL="+os.DevNull,
+		"GIT_AUTHOR_NAME=A U Thor",
+		"GIT_AUTHOR_EMAIL=author@example.com",
+		"GIT_COMMITTER_NAME=A U Thor",
+		"GIT_COMMITTER_EMAIL=author@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newWorktreeFixture creates a repository whose main worktree is on main, with a detached linked
+// worktree and one on the topic branch, each one commit ahead of main. It returns the path of
+// the main worktree and the HEADs of the linked ones.
+func newWorktreeFixture(t *testing.T) (mainDir string, detachedHead, topicHead Hash) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	dir := t.TempDir()
+	mainDir = filepath.Join(dir, "main")
+	detachedDir, topicDir := filepath.Join(dir, "detached"), filepath.Join(dir, "topic")
+
+	runGit(t, dir, "init", "-q", "-b", "main", mainDir)
+	runGit(t, mainDir, "commit", "-q", "--allow-empty", "-m", "one")
+	runGit(t, mainDir, "worktree", "add", "-q", "--detach", detachedDir)
+	runGit(t, detachedDir, "commit", "-q", "--allow-empty", "-m", "detached")
+	runGit(t, mainDir, "worktree", "add", "-q", "-b", "topic", topicDir)
+	runGit(t, topicDir, "commit", "-q", "--allow-empty", "-m", "topic")
+
+	return mainDir, Hash(runGit(t, detachedDir, "rev-parse", "HEAD")), Hash(runGit(t, topicDir, "rev-parse", "HEAD"))
+}
+
+func TestDetachedWorktreeHeadIsLoaded(t *testing.T) {
+	mainDir, detachedHead, _ := newWorktreeFixture(t)
+
+	repo, err := NewRepositoryWithOptions(mainDir, Options{})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions: %v", err)
+	}
+	defer repo.Close()
+
+	found := false
+	for _, worktree := range repo.Worktrees() {
+		if worktree.Detached && worktree.Head == detachedHead {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("no detached worktree at %s in %+v", detachedHead, repo.Worktrees())
+	}
+	if _, found := repo.Commits()[detachedHead]; !found {
+		t.Errorf("HEAD %s of the detached worktree was not loaded", detachedHead)
+	}
+}
+
+func TestFilteredWorktreeBranchHeadIsLoaded(t *testing.T) {
+	mainDir, _, topicHead := newWorktreeFixture(t)
+
+	repo, err := NewRepositoryWithOptions(mainDir, Options{
+		Refs: RefFilter{Exclude: []string{"refs/heads/topic"}},
+	})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions: %v", err)
+	}
+	defer repo.Close()
+
+	if _, found := repo.Branches()["topic"]; found {
+		t.Error("excluded branch refs/heads/topic was loaded")
+	}
+	if _, found := repo.Commits()[topicHead]; !found {
+		t.Errorf("HEAD %s of the worktree on an excluded branch was not loaded", topicHead)
+	}
+}
@@ -28,14 +28,17 @@ func (s *Server) handleBroadcast() {
 	}
 }
 
-// sendToAllClients broadcasts message to all connected WebSocket clients.
-// It removes clients that fail to receive message.
+// sendToAllClients broadcasts message to all connected WebSocket clients viewing the repository
+// it applies to. It removes clients that fail to receive message.
 func (s *Server) sendToAllClients(message UpdateMessage) {
 	var failedClients []*websocket.Conn
 
 	// Send to all clients (read lock only)
 	s.clientsMu.RLock()
-	for client := range s.clients {
+	for client, submodule := range s.clients {
+		if submodule != message.Submodule {
+			continue
+		}
 		client.SetWriteDeadline(time.Now().Add(writeWait))
 		if err := client.WriteJSON(message); err != nil {
 			log.Printf("Broadcast failed to %s: %v", client.RemoteAddr(), err)
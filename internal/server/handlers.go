@@ -2,19 +2,34 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
+	"github.com/rybkr/gitvista/internal/gitcore"
+	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 )
 
 // handleRepository serves repository metadata via REST API.
 // Used for initial page load and debugging.
 func (s *Server) handleRepository(w http.ResponseWriter, r *http.Request) {
-	s.cacheMu.RLock()
-	repo := s.cached.repo
-	s.cacheMu.RUnlock()
+	repo, release, err := s.requestRepository(r)
+	if err != nil {
+		http.Error(w, "Submodule not found", http.StatusNotFound)
+		return
+	}
+	defer release()
 
 	response := map[string]interface{}{
-		"name":   repo.Name(),
-		"gitDir": repo.GitDir(),
+		"name":         repo.Name(),
+		"gitDir":       repo.GitDir(),
+		"objectFormat": repo.ObjectFormat(),
+		"bare":         repo.IsBare(),
+		"packCache":    repo.PackCacheStats(),
+		"remotes":      repo.Remotes(),
+		"upstreams":    repo.Upstreams(),
+		"worktrees":    repo.Worktrees(),
+		"submodules":   repo.Submodules(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -22,3 +37,376 @@ func (s *Server) handleRepository(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 	}
 }
+
+// handleCommit serves the full metadata of a single commit.
+// Used to fill in commits that were sent to clients without author or message.
+func (s *Server) handleCommit(w http.ResponseWriter, r *http.Request) {
+	hash, err := gitcore.NewHash(r.PathValue("hash"))
+	if err != nil {
+		http.Error(w, "Invalid commit hash", http.StatusBadRequest)
+		return
+	}
+
+	repo, release, err := s.requestRepository(r)
+	if err != nil {
+		http.Error(w, "Submodule not found", http.StatusNotFound)
+		return
+	}
+	defer release()
+
+	commit, err := repo.Commit(hash)
+	if err != nil {
+		http.Error(w, "Commit not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(commit); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleCommitChanges serves the list of files touched by a single commit.
+// Used by the commit tooltip and detail view.
+// Accepts optional "renames" (a similarity percentage, or "off") and "copies" query parameters.
+func (s *Server) handleCommitChanges(w http.ResponseWriter, r *http.Request) {
+	hash, err := gitcore.NewHash(r.PathValue("hash"))
+	if err != nil {
+		http.Error(w, "Invalid commit hash", http.StatusBadRequest)
+		return
+	}
+
+	renames, err := parseRenameOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Invalid rename options", http.StatusBadRequest)
+		return
+	}
+
+	repo, release, err := s.requestRepository(r)
+	if err != nil {
+		http.Error(w, "Submodule not found", http.StatusNotFound)
+		return
+	}
+	defer release()
+
+	if _, err := repo.Commit(hash); err != nil {
+		http.Error(w, "Commit not found", http.StatusNotFound)
+		return
+	}
+	changes, err := repo.CommitChanges(hash, renames)
+	if err != nil {
+		// The commit exists, so its parent or one of the trees could not be read.
+		log.Printf("%s Failed to compute changes of %s: %v", logError, hash, err)
+		http.Error(w, "Failed to compute changes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(changes); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleCommitDiff serves the line-level patch of every file touched by a single commit.
+// Accepts optional "context", "algorithm" and "whitespace" query parameters, as well as the
+// rename detection parameters of handleCommitChanges.
+func (s *Server) handleCommitDiff(w http.ResponseWriter, r *http.Request) {
+	hash, err := gitcore.NewHash(r.PathValue("hash"))
+	if err != nil {
+		http.Error(w, "Invalid commit hash", http.StatusBadRequest)
+		return
+	}
+
+	opts := gitcore.DefaultDiffOptions()
+	query := r.URL.Query()
+	if context := query.Get("context"); context != "" {
+		n, err := strconv.Atoi(context)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid context", http.StatusBadRequest)
+			return
+		}
+		opts.Context = n
+	}
+	switch algorithm := gitcore.DiffAlgorithm(query.Get("algorithm")); algorithm {
+	case "":
+	case gitcore.DiffMyers, gitcore.DiffPatience, gitcore.DiffHistogram:
+		opts.Algorithm = algorithm
+	default:
+		http.Error(w, "Invalid algorithm", http.StatusBadRequest)
+		return
+	}
+	switch query.Get("whitespace") {
+	case "":
+	case "eol":
+		opts.Whitespace = gitcore.IgnoreWhitespaceAtEOL
+	case "change":
+		opts.Whitespace = gitcore.IgnoreWhitespaceChange
+	case "all":
+		opts.Whitespace = gitcore.IgnoreAllWhitespace
+	default:
+		http.Error(w, "Invalid whitespace mode", http.StatusBadRequest)
+		return
+	}
+	if opts.Renames, err = parseRenameOptions(query); err != nil {
+		http.Error(w, "Invalid rename options", http.StatusBadRequest)
+		return
+	}
+
+	repo, release, err := s.requestRepository(r)
+	if err != nil {
+		http.Error(w, "Submodule not found", http.StatusNotFound)
+		return
+	}
+	defer release()
+
+	patches, err := repo.CommitPatch(hash, opts)
+	if err != nil {
+		http.Error(w, "Commit not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(patches); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleBlame serves line authorship for the file named by the "path" query parameter, as of a
+// single commit.
+func (s *Server) handleBlame(w http.ResponseWriter, r *http.Request) {
+	hash, err := gitcore.NewHash(r.PathValue("hash"))
+	if err != nil {
+		http.Error(w, "Invalid commit hash", http.StatusBadRequest)
+		return
+	}
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "Missing path", http.StatusBadRequest)
+		return
+	}
+
+	repo, release, err := s.requestRepository(r)
+	if err != nil {
+		http.Error(w, "Submodule not found", http.StatusNotFound)
+		return
+	}
+	defer release()
+
+	lines, err := repo.Blame(hash, path)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(lines); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleFileHistory serves the commits reachable from a commit that changed the path named by the
+// "path" query parameter. Accepts optional "follow" and "limit" query parameters.
+func (s *Server) handleFileHistory(w http.ResponseWriter, r *http.Request) {
+	hash, err := gitcore.NewHash(r.PathValue("hash"))
+	if err != nil {
+		http.Error(w, "Invalid commit hash", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	path := query.Get("path")
+	if path == "" {
+		http.Error(w, "Missing path", http.StatusBadRequest)
+		return
+	}
+
+	var opts gitcore.HistoryOptions
+	if follow := query.Get("follow"); follow != "" {
+		if opts.Follow, err = strconv.ParseBool(follow); err != nil {
+			http.Error(w, "Invalid follow flag", http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if opts.Limit, err = strconv.Atoi(limit); err != nil || opts.Limit < 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	repo, release, err := s.requestRepository(r)
+	if err != nil {
+		http.Error(w, "Submodule not found", http.StatusNotFound)
+		return
+	}
+	defer release()
+
+	entries, err := repo.FileHistory(hash, path, opts)
+	if err != nil {
+		http.Error(w, "Commit not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleCommitSubmodules serves the submodules declared at a single commit, each with the
+// submodule commit that the superproject commit pins.
+func (s *Server) handleCommitSubmodules(w http.ResponseWriter, r *http.Request) {
+	hash, err := gitcore.NewHash(r.PathValue("hash"))
+	if err != nil {
+		http.Error(w, "Invalid commit hash", http.StatusBadRequest)
+		return
+	}
+
+	repo, release, err := s.requestRepository(r)
+	if err != nil {
+		http.Error(w, "Submodule not found", http.StatusNotFound)
+		return
+	}
+	defer release()
+
+	submodules, err := repo.CommitSubmodules(hash)
+	if err != nil {
+		http.Error(w, "Commit not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(submodules); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleSubmodules serves the submodules of the commit at HEAD.
+func (s *Server) handleSubmodules(w http.ResponseWriter, r *http.Request) {
+	repo, release, err := s.requestRepository(r)
+	if err != nil {
+		http.Error(w, "Submodule not found", http.StatusNotFound)
+		return
+	}
+	defer release()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(repo.Submodules()); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleTags serves every tag with the object it ultimately points to.
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	repo, release, err := s.requestRepository(r)
+	if err != nil {
+		http.Error(w, "Submodule not found", http.StatusNotFound)
+		return
+	}
+	defer release()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(repo.Tags()); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleReflogRefs serves the names of all refs that have a reflog.
+func (s *Server) handleReflogRefs(w http.ResponseWriter, r *http.Request) {
+	repo, release, err := s.requestRepository(r)
+	if err != nil {
+		http.Error(w, "Submodule not found", http.StatusNotFound)
+		return
+	}
+	defer release()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(repo.ReflogRefs()); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleReflog serves the reflog of a single ref, given as HEAD or a full ref name.
+func (s *Server) handleReflog(w http.ResponseWriter, r *http.Request) {
+	repo, release, err := s.requestRepository(r)
+	if err != nil {
+		http.Error(w, "Submodule not found", http.StatusNotFound)
+		return
+	}
+	defer release()
+
+	entries := repo.Reflog(r.PathValue("ref"))
+	if entries == nil {
+		http.Error(w, "Reflog not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleRefs serves the repository's refs, optionally narrowed by repeated "kind", "include" and
+// "exclude" query parameters with the semantics of gitcore.RefFilter.
+func (s *Server) handleRefs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := gitcore.RefFilter{
+		Include: query["include"],
+		Exclude: query["exclude"],
+	}
+	for _, kind := range query["kind"] {
+		switch kind := gitcore.RefKind(kind); kind {
+		case gitcore.RefBranch, gitcore.RefRemoteBranch, gitcore.RefTag,
+			gitcore.RefPullRequest, gitcore.RefChange, gitcore.RefOther:
+			filter.Kinds = append(filter.Kinds, kind)
+		default:
+			http.Error(w, "Invalid ref kind", http.StatusBadRequest)
+			return
+		}
+	}
+	filter, err := filter.Compile()
+	if err != nil {
+		http.Error(w, "Invalid ref pattern", http.StatusBadRequest)
+		return
+	}
+
+	repo, release, err := s.requestRepository(r)
+	if err != nil {
+		http.Error(w, "Submodule not found", http.StatusNotFound)
+		return
+	}
+	defer release()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(repo.Refs(filter)); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// parseRenameOptions reads rename and copy detection settings from query parameters.
+func parseRenameOptions(query url.Values) (gitcore.RenameOptions, error) {
+	opts := gitcore.DefaultRenameOptions()
+
+	switch renames := query.Get("renames"); renames {
+	case "":
+	case "off":
+		opts.Renames = false
+	default:
+		threshold, err := strconv.Atoi(renames)
+		if err != nil || threshold < 0 || threshold > 100 {
+			return opts, errors.New("invalid rename threshold")
+		}
+		opts.Threshold = threshold
+	}
+
+	if copies := query.Get("copies"); copies != "" {
+		enabled, err := strconv.ParseBool(copies)
+		if err != nil {
+			return opts, errors.New("invalid copies flag")
+		}
+		opts.Copies = enabled
+	}
+
+	return opts, nil
+}
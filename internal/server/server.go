@@ -16,11 +16,13 @@ type Server struct {
 
 	cacheMu sync.RWMutex
 	cached struct {
-		repo *gitcore.Repository
+		repo       *servedRepository
+		submodules map[string]*servedRepository
 	}
 
 	clientsMu sync.RWMutex
-	clients   map[*websocket.Conn]bool
+	// clients maps each connection to the submodule it views, or to "" for the superproject.
+	clients map[*websocket.Conn]string
 
 	broadcast chan UpdateMessage
 
@@ -36,12 +38,13 @@ func NewServer(repo *gitcore.Repository, port string) *Server {
 	s := &Server{
 		repo:      repo,
 		port:      port,
-		clients:   make(map[*websocket.Conn]bool),
+		clients:   make(map[*websocket.Conn]string),
 		broadcast: make(chan UpdateMessage, broadcastChannelSize),
 		ctx:       ctx,
 		cancel:    cancel,
 	}
-	s.cached.repo = repo
+	s.cached.repo = newServedRepository(repo)
+	s.cached.submodules = make(map[string]*servedRepository)
 
 	return s
 }
@@ -54,6 +57,17 @@ func (s *Server) Start() error {
 	http.Handle("/", fs)
 
 	http.HandleFunc("/api/repository", s.handleRepository)
+	http.HandleFunc("GET /api/commits/{hash}", s.handleCommit)
+	http.HandleFunc("GET /api/commits/{hash}/changes", s.handleCommitChanges)
+	http.HandleFunc("GET /api/commits/{hash}/diff", s.handleCommitDiff)
+	http.HandleFunc("GET /api/commits/{hash}/blame", s.handleBlame)
+	http.HandleFunc("GET /api/commits/{hash}/history", s.handleFileHistory)
+	http.HandleFunc("GET /api/refs", s.handleRefs)
+	http.HandleFunc("GET /api/commits/{hash}/submodules", s.handleCommitSubmodules)
+	http.HandleFunc("GET /api/tags", s.handleTags)
+	http.HandleFunc("GET /api/submodules", s.handleSubmodules)
+	http.HandleFunc("GET /api/reflog", s.handleReflogRefs)
+	http.HandleFunc("GET /api/reflog/{ref...}", s.handleReflog)
 	http.HandleFunc("/api/ws", s.handleWebSocket)
 
 	s.wg.Add(1)
@@ -75,7 +89,7 @@ func (s *Server) Shutdown() {
 	for conn := range s.clients {
 		conn.Close()
 	}
-	s.clients = make(map[*websocket.Conn]bool)
+	s.clients = make(map[*websocket.Conn]string)
 	s.clientsMu.Unlock()
     
     log.Printf("%s Server shutdown complete", logSuccess)
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"github.com/rybkr/gitvista/internal/gitcore"
+	"log"
+	"net/http"
+)
+
+// repository returns the repository being served, or one of its submodules when name is given,
+// along with the function that releases it once the caller is done reading from it.
+// Submodules are opened on first use and reloaded whenever their own git directory changes.
+func (s *Server) repository(name string) (*gitcore.Repository, func(), error) {
+	s.cacheMu.RLock()
+	repo, submodule := s.cached.repo, s.cached.submodules[name]
+	var release func()
+	if name == "" {
+		release = repo.acquire()
+	} else if submodule != nil {
+		release = submodule.acquire()
+	}
+	s.cacheMu.RUnlock()
+
+	if name == "" {
+		return repo.Repository, release, nil
+	}
+	if submodule != nil {
+		return submodule.Repository, release, nil
+	}
+
+	opened, err := repo.OpenSubmodule(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	submodule = newServedRepository(opened)
+
+	s.cacheMu.Lock()
+	if cached := s.cached.submodules[name]; cached != nil {
+		// Another request opened it first.
+		submodule.retire()
+		submodule = cached
+	} else {
+		s.cached.submodules[name] = submodule
+	}
+	release = submodule.acquire()
+	s.cacheMu.Unlock()
+	return submodule.Repository, release, nil
+}
+
+// requestRepository returns the repository a request is about: the submodule named by its
+// "submodule" query parameter, or the superproject. The caller must release it when done.
+func (s *Server) requestRepository(r *http.Request) (*gitcore.Repository, func(), error) {
+	return s.repository(r.URL.Query().Get("submodule"))
+}
+
+// updateSubmodules reloads the submodules opened so far whose git directory contains a changed
+// path, and broadcasts their changes to the clients viewing them. A replaced submodule is closed
+// once the requests reading from it finish. Submodules that can no longer be opened, e.g. after
+// their git directory was removed, are dropped.
+func (s *Server) updateSubmodules(changed []string) {
+	s.cacheMu.RLock()
+	stale := make(map[string]*servedRepository)
+	for name, submodule := range s.cached.submodules {
+		for _, path := range changed {
+			if isUnder(path, submodule.GitDir()) {
+				stale[name] = submodule
+				break
+			}
+		}
+	}
+	s.cacheMu.RUnlock()
+
+	for name, old := range stale {
+		log.Printf("Updating submodule %s...", name)
+		reloaded, err := gitcore.NewRepositoryWithOptions(old.GitDir(), old.Options())
+
+		s.cacheMu.Lock()
+		current := s.cached.submodules[name] == old
+		if current {
+			if err != nil {
+				delete(s.cached.submodules, name)
+			} else {
+				s.cached.submodules[name] = newServedRepository(reloaded)
+			}
+		}
+		s.cacheMu.Unlock()
+
+		if !current {
+			// Dropped or replaced meanwhile; the newer copy is the one served.
+			if err == nil {
+				newServedRepository(reloaded).retire()
+			}
+			continue
+		}
+		if err != nil {
+			old.retire()
+			log.Printf("%s Failed to reload submodule %s: %v", logWarning, name, err)
+			continue
+		}
+		delta := reloaded.Diff(old.Repository)
+		old.retire()
+		if !delta.IsEmpty() {
+			s.broadcastUpdate(UpdateMessage{Delta: delta, Submodule: name})
+		}
+	}
+}
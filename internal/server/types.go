@@ -2,6 +2,8 @@ package server
 
 import (
 	"github.com/rybkr/gitvista/internal/gitcore"
+	"log"
+	"sync"
 )
 
 // Log prefixes for visual scanning of logs.
@@ -15,4 +17,36 @@ const (
 // UpdateMessage is sents to clients via WebSocket.
 type UpdateMessage struct {
 	Delta *gitcore.RepositoryDelta `json:"delta"`
+	// Submodule names the submodule the delta applies to, or is empty for the superproject.
+	Submodule string `json:"submodule,omitempty"`
+}
+
+// servedRepository is a repository together with the requests still reading from it.
+// A reload replaces it, and the replaced repository is only closed once those requests finish.
+type servedRepository struct {
+	*gitcore.Repository
+	readers sync.WaitGroup
+}
+
+// newServedRepository wraps a freshly opened repository.
+func newServedRepository(repo *gitcore.Repository) *servedRepository {
+	return &servedRepository{Repository: repo}
+}
+
+// acquire registers a reader and returns the function that releases it.
+// Callers must hold the lock that guards where the repository was found, so that no reader is
+// registered after the repository has been retired.
+func (r *servedRepository) acquire() func() {
+	r.readers.Add(1)
+	return r.readers.Done
+}
+
+// retire closes the repository once its remaining readers have finished.
+func (r *servedRepository) retire() {
+	go func() {
+		r.readers.Wait()
+		if err := r.Close(); err != nil {
+			log.Printf("%s Failed to close repository %s: %v", logWarning, r.Name(), err)
+		}
+	}()
 }
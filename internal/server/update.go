@@ -3,18 +3,27 @@ package server
 import (
 	"github.com/rybkr/gitvista/internal/gitcore"
 	"log"
+	"path/filepath"
+	"strings"
 )
 
 // updateRepository reloads repository state and broadcasts changes to clients
-// Called by filesystem watcher when Git operations are detected
-func (s *Server) updateRepository() {
+// Called by filesystem watcher when Git operations are detected, with the paths that changed
+func (s *Server) updateRepository(changed []string) {
+	s.updateSubmodules(changed)
+	modulesDir := filepath.Join(s.repo.CommonDir(), "modules")
+	if !hasChangeOutside(changed, modulesDir) {
+		// Only submodules changed; the superproject's own state did not.
+		return
+	}
+
 	log.Println("Updating repository...")
 
 	s.cacheMu.RLock()
 	oldRepo := s.cached.repo
 	s.cacheMu.RUnlock()
 
-	newRepo, err := gitcore.NewRepository(s.repo.GitDir())
+	newRepo, err := gitcore.NewRepositoryWithOptions(s.repo.GitDir(), s.repo.Options())
 	if err != nil {
 		log.Printf("ERROR: Failed to reload repository: %v", err)
 		return
@@ -22,16 +31,21 @@ func (s *Server) updateRepository() {
 
 	var delta *gitcore.RepositoryDelta
 	if oldRepo != nil {
-		delta = newRepo.Diff(oldRepo)
+		delta = newRepo.Diff(oldRepo.Repository)
 	} else {
 		// First update: treat everything as new
 		delta = newRepo.Diff(&gitcore.Repository{})
 	}
 
+	served := newServedRepository(newRepo)
 	s.cacheMu.Lock()
 	s.repo = newRepo
-	s.cached.repo = newRepo
+	s.cached.repo = served
 	s.cacheMu.Unlock()
+	if oldRepo != nil {
+		// Requests still reading from the old repository keep its pack files open until they finish.
+		oldRepo.retire()
+	}
 
 	if !delta.IsEmpty() {
 		s.broadcastUpdate(UpdateMessage{Delta: delta})
@@ -39,3 +53,18 @@ func (s *Server) updateRepository() {
 		log.Println("No changes detected")
 	}
 }
+
+// isUnder reports whether path is dir or lies within it.
+func isUnder(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// hasChangeOutside reports whether any changed path lies outside of dir.
+func hasChangeOutside(changed []string, dir string) bool {
+	for _, path := range changed {
+		if !isUnder(path, dir) {
+			return true
+		}
+	}
+	return false
+}
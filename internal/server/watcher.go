@@ -2,9 +2,12 @@ package server
 
 import (
 	"github.com/fsnotify/fsnotify"
+	"io/fs"
 	"log"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -13,15 +16,37 @@ const (
 )
 
 // startWatcher initializes filesystem monitoring for the Git repository.
-// It watches the .git/ directory for changes and triggers updates.
+// It watches the .git/ directory for changes and triggers updates. In a repository with linked
+// worktrees, it also watches the shared directory and every worktree's private directory, since
+// each worktree moves its own HEAD.
 func (s *Server) startWatcher() error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return err
 	}
 
-	if err := watcher.Add(s.repo.GitDir()); err != nil {
-		return err
+	gitDir, commonDir := s.repo.GitDir(), s.repo.CommonDir()
+	dirs := []string{
+		gitDir,
+		commonDir,
+		// Reflogs are a data source in their own right; logs/HEAD changes with every ref update.
+		filepath.Join(gitDir, "logs"),
+		filepath.Join(commonDir, "logs"),
+		// Reftable repositories record every ref update by rewriting reftable/tables.list.
+		filepath.Join(gitDir, "reftable"),
+		filepath.Join(commonDir, "reftable"),
+		filepath.Join(commonDir, "worktrees"),
+	}
+	worktreeDirs, _ := filepath.Glob(filepath.Join(commonDir, "worktrees", "*"))
+	dirs = append(dirs, worktreeDirs...)
+	// The reflog of refs/heads/topic is logs/refs/heads/topic, and fsnotify watches no subdirectories.
+	dirs = append(dirs, reflogWatchDirs(filepath.Join(commonDir, "logs", "refs"))...)
+	// Submodules cloned under modules/ move their own HEAD and reflogs, which drilled-in views show.
+	dirs = append(dirs, submoduleWatchDirs(filepath.Join(commonDir, "modules"))...)
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil && !os.IsNotExist(err) {
+			return err
+		}
 	}
 
 	s.wg.Add(1)
@@ -37,7 +62,10 @@ func (s *Server) watchLoop(watcher *fsnotify.Watcher) {
 	defer s.wg.Done()
 	defer watcher.Close()
 
+	reflogsDir := filepath.Join(s.repo.CommonDir(), "logs", "refs")
 	var debounceTimer *time.Timer
+	var pendingMu sync.Mutex
+	var pending []string
 
 	for {
 		select {
@@ -51,14 +79,38 @@ func (s *Server) watchLoop(watcher *fsnotify.Watcher) {
 			if shouldIgnoreEvent(event) {
 				continue
 			}
+			if parent := filepath.Base(filepath.Dir(event.Name)); event.Op&fsnotify.Create != 0 &&
+				(parent == "worktrees" || parent == "modules") {
+				// A worktree was added or a submodule cloned; its HEAD lives in the new directory.
+				if err := watcher.Add(event.Name); err != nil {
+					log.Printf("%s Failed to watch %s: %v", logWarning, filepath.Base(event.Name), err)
+				}
+			}
+			if event.Op&fsnotify.Create != 0 && isUnder(event.Name, reflogsDir) {
+				// The first ref of a new namespace, e.g. refs/heads/feature/x, creates its log
+				// directory. Nested ones may already exist by the time the event arrives.
+				for _, dir := range reflogWatchDirs(event.Name) {
+					if err := watcher.Add(dir); err != nil && !os.IsNotExist(err) {
+						log.Printf("%s Failed to watch %s: %v", logWarning, filepath.Base(dir), err)
+					}
+				}
+			}
 
 			log.Printf("%s Change detected: %s", logInfo, filepath.Base(event.Name))
 
+			pendingMu.Lock()
+			pending = append(pending, event.Name)
+			pendingMu.Unlock()
+
 			if debounceTimer != nil {
 				debounceTimer.Stop()
 			}
 			debounceTimer = time.AfterFunc(debounceTime, func() {
-				s.updateRepository()
+				pendingMu.Lock()
+				changed := pending
+				pending = nil
+				pendingMu.Unlock()
+				s.updateRepository(changed)
 			})
 
 		case err, ok := <-watcher.Errors:
@@ -70,11 +122,45 @@ func (s *Server) watchLoop(watcher *fsnotify.Watcher) {
 	}
 }
 
+// submoduleWatchDirs returns the directories to watch for submodules cloned under modulesDir: the
+// git directory of each submodule and its logs, and the directories leading to them, since a
+// submodule named like "deps/lib" lives in modules/deps/lib.
+func submoduleWatchDirs(modulesDir string) []string {
+	var dirs []string
+	filepath.WalkDir(modulesDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		dirs = append(dirs, path)
+		if _, err := os.Stat(filepath.Join(path, "HEAD")); err == nil {
+			dirs = append(dirs, filepath.Join(path, "logs"))
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return dirs
+}
+
+// reflogWatchDirs returns dir and every directory below it, or nothing if dir is a file, such as a
+// reflog itself.
+func reflogWatchDirs(dir string) []string {
+	var dirs []string
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	return dirs
+}
+
 // shouldIgnoreEvent reports whether a file system event should be ignored for our purposes.
-// For example, a change to an internal log file does not warrant a repository update.
+// For example, a change to a lock file does not warrant a repository update.
 func shouldIgnoreEvent(event fsnotify.Event) bool {
 	base := filepath.Base(event.Name)
-	path := event.Name
 
 	if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
 		return true
@@ -82,12 +168,6 @@ func shouldIgnoreEvent(event fsnotify.Event) bool {
 	if strings.HasSuffix(base, ".lock") {
 		return true
 	}
-	if strings.Contains(path, "/logs/") {
-		return true
-	}
-	if base == "config" {
-		return true
-	}
 
 	return false
 }
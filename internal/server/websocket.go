@@ -26,7 +26,16 @@ var upgrader = websocket.Upgrader{
 }
 
 // handleWebsocket upgrades HTTP connection to WebSocket and manages client lifecycle.
+// A "submodule" query parameter subscribes the client to that submodule instead of the superproject.
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	submodule := r.URL.Query().Get("submodule")
+	repo, release, err := s.repository(submodule)
+	if err != nil {
+		http.Error(w, "Submodule not found", http.StatusNotFound)
+		return
+	}
+	defer release()
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("%s WebSocket upgrade failed: %v", logError, err)
@@ -44,10 +53,10 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	// Send initial state before registering for broadcasts.
 	// Prevents race where broadcast arrives before client knows baseline state.
-	s.sendInitialState(conn)
+	s.sendInitialState(conn, repo)
 
 	s.clientsMu.Lock()
-	s.clients[conn] = true
+	s.clients[conn] = submodule
 	clientCount := len(s.clients)
 	s.clientsMu.Unlock()
 
@@ -59,11 +68,7 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 }
 
 // sendInitialState sends full repository state as a delta to a new client.
-func (s *Server) sendInitialState(conn *websocket.Conn) {
-	s.cacheMu.RLock()
-	repo := s.cached.repo
-	s.cacheMu.RUnlock()
-
+func (s *Server) sendInitialState(conn *websocket.Conn, repo *gitcore.Repository) {
 	message := UpdateMessage{
 		Delta: repo.Diff(&gitcore.Repository{}),
 	}
@@ -135,7 +140,7 @@ func (s *Server) removeClient(conn *websocket.Conn) {
 	s.clientsMu.Lock()
 	defer s.clientsMu.Unlock()
 
-	if s.clients[conn] {
+	if _, ok := s.clients[conn]; ok {
 		delete(s.clients, conn)
 		conn.Close()
 		log.Printf("%s WebSocket client removed. Total clients: %d", logInfo, len(s.clients))
@@ -1,3 +1,5 @@
+import { apiPath } from "./utils/api.js";
+
 export async function startBackend({ onDelta, logger }) {
     await loadRepositoryMetadata(logger);
     return openWebSocket({ onDelta, logger });
@@ -6,7 +8,7 @@ export async function startBackend({ onDelta, logger }) {
 async function loadRepositoryMetadata(logger) {
     logger?.info("Requesting repository metadata");
     try {
-        const response = await fetch("/api/repository");
+        const response = await fetch(apiPath("/api/repository"));
         if (!response.ok) {
             throw new Error(`HTTP ${response.status}`);
         }
@@ -19,7 +21,7 @@ async function loadRepositoryMetadata(logger) {
 
 function openWebSocket({ onDelta, logger }) {
     const protocol = window.location.protocol === "https:" ? "wss" : "ws";
-    const url = `${protocol}://${window.location.host}/api/ws`;
+    const url = `${protocol}://${window.location.host}${apiPath("/api/ws")}`;
     logger?.info("Opening WebSocket connection", url);
 
     try {
@@ -33,4 +33,11 @@ export const BRANCH_NODE_RADIUS = 18;
 export const TOOLTIP_OFFSET_X = 18;
 export const TOOLTIP_OFFSET_Y = -24;
 export const HIGHLIGHT_NODE_RADIUS = NODE_RADIUS + 2.5;
+export const STASH_NODE_SIZE = 10;
+export const STASH_NODE_OFFSET_X = -28;
+export const STASH_NODE_OFFSET_Y = 18;
+export const SHALLOW_MARKER_GAP = 4;
+export const SHALLOW_MARKER_LABEL = "history truncated here";
+export const WORKTREE_MARKER_GAP = 4;
+export const WORKTREE_MARKER_LINE_HEIGHT = 14;
 
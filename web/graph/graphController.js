@@ -15,6 +15,8 @@ import {
 	LINK_DISTANCE,
 	LINK_STRENGTH,
 	NODE_RADIUS,
+	STASH_NODE_OFFSET_X,
+	STASH_NODE_OFFSET_Y,
 	ZOOM_MAX,
 	ZOOM_MIN,
 } from "./constants.js";
@@ -36,7 +38,7 @@ export function createGraphController(rootElement) {
 	rootElement.appendChild(canvas);
 
 	const state = createGraphState();
-	const { commits, branches, nodes, links } = state;
+	const { commits, branches, remoteBranches, stashes, worktrees, nodes, links } = state;
 
 	let zoomTransform = state.zoomTransform;
 	let dragState = null;
@@ -298,9 +300,12 @@ export function createGraphController(rootElement) {
 	function updateGraph() {
 		const existingCommitNodes = new Map();
 		const existingBranchNodes = new Map();
+		const existingStashNodes = new Map();
 		for (const node of nodes) {
 			if (node.type === "branch" && node.branch) {
-				existingBranchNodes.set(node.branch, node);
+				existingBranchNodes.set(branchKey(node.branch, node.remote), node);
+			} else if (node.type === "stash" && node.hash) {
+				existingStashNodes.set(node.hash, node);
 			} else if (node.type === "commit" && node.hash) {
 				existingCommitNodes.set(node.hash, node);
 			}
@@ -324,6 +329,19 @@ export function createGraphController(rootElement) {
 			}
 		}
 
+		const worktreesByHead = new Map();
+		for (const worktree of worktrees.values()) {
+			if (!worktree.head) {
+				continue;
+			}
+			const list = worktreesByHead.get(worktree.head) ?? [];
+			list.push(worktree);
+			worktreesByHead.set(worktree.head, list);
+		}
+		for (const node of nextCommitNodes) {
+			node.worktrees = worktreesByHead.get(node.hash);
+		}
+
 		const commitHashes = new Set(nextCommitNodes.map((node) => node.hash));
 		const previousLinkCount = links.length;
 		const nextLinks = [];
@@ -347,14 +365,19 @@ export function createGraphController(rootElement) {
 		);
 		const nextBranchNodes = [];
 		const pendingBranchAlignments = [];
-		let branchStructureChanged = existingBranchNodes.size !== branches.size;
-		for (const [branchName, targetHash] of branches.entries()) {
+		const branchEntries = [
+			...[...branches.entries()].map(([name, hash]) => [name, hash, false]),
+			...[...remoteBranches.entries()].map(([name, hash]) => [name, hash, true]),
+		];
+		let branchStructureChanged =
+			existingBranchNodes.size !== branchEntries.length;
+		for (const [branchName, targetHash, remote] of branchEntries) {
 			const targetNode = commitNodeByHash.get(targetHash);
 			if (!targetNode) {
 				continue;
 			}
 
-			let branchNode = existingBranchNodes.get(branchName);
+			let branchNode = existingBranchNodes.get(branchKey(branchName, remote));
 			const isNewNode = !branchNode;
 			if (!branchNode) {
 				branchNode = createBranchNode(branchName, targetNode);
@@ -363,6 +386,7 @@ export function createGraphController(rootElement) {
 			const previousHash = branchNode.targetHash;
 			branchNode.type = "branch";
 			branchNode.branch = branchName;
+			branchNode.remote = remote;
 			branchNode.targetHash = targetHash;
 			if (isNewNode) {
 				branchNode.spawnPhase = 0;
@@ -381,7 +405,36 @@ export function createGraphController(rootElement) {
 			pendingBranchAlignments.push({ branchNode, targetNode });
 		}
 
-		nodes.splice(0, nodes.length, ...nextCommitNodes, ...nextBranchNodes);
+		const nextStashNodes = [];
+		let stashStructureChanged = existingStashNodes.size !== stashes.size;
+		for (const stash of stashes.values()) {
+			const baseNode = commitNodeByHash.get(stash.base);
+			if (!baseNode) {
+				continue;
+			}
+
+			let stashNode = existingStashNodes.get(stash.hash);
+			if (!stashNode) {
+				stashNode = createStashNode(stash, baseNode);
+				stashStructureChanged = true;
+			}
+			stashNode.stash = stash;
+
+			nextStashNodes.push(stashNode);
+			nextLinks.push({
+				source: stashNode,
+				target: baseNode,
+				kind: "stash",
+			});
+		}
+
+		nodes.splice(
+			0,
+			nodes.length,
+			...nextCommitNodes,
+			...nextBranchNodes,
+			...nextStashNodes,
+		);
 		links.splice(0, links.length, ...nextLinks);
 
 		if (dragState && !nodes.includes(dragState.node)) {
@@ -398,7 +451,10 @@ export function createGraphController(rootElement) {
 
 		const linkStructureChanged = previousLinkCount !== nextLinks.length;
 		const structureChanged =
-			commitStructureChanged || branchStructureChanged || linkStructureChanged;
+			commitStructureChanged ||
+			branchStructureChanged ||
+			stashStructureChanged ||
+			linkStructureChanged;
 		const hasCommits = nextCommitNodes.length > 0;
 
 		if (!initialLayoutComplete && hasCommits) {
@@ -469,6 +525,25 @@ export function createGraphController(rootElement) {
 		}
 	}
 
+	function createStashNode(stash, baseNode) {
+		const jitter = (range) => (Math.random() - 0.5) * range;
+		return {
+			type: "stash",
+			hash: stash.hash,
+			stash,
+			targetHash: stash.base,
+			spawnPhase: 0,
+			x: (baseNode.x ?? 0) + STASH_NODE_OFFSET_X + jitter(4),
+			y: (baseNode.y ?? 0) + STASH_NODE_OFFSET_Y + jitter(4),
+			vx: 0,
+			vy: 0,
+		};
+	}
+
+	function branchKey(branchName, remote) {
+		return remote ? `remotes/${branchName}` : branchName;
+	}
+
 	function createBranchNode(branchName, targetNode) {
 		if (targetNode) {
 			const jitter = (range) => (Math.random() - 0.5) * range;
@@ -560,6 +635,69 @@ export function createGraphController(rootElement) {
 			branches.delete(name);
 		}
 
+		for (const [name, hash] of Object.entries(delta.addedRemoteBranches || {})) {
+			if (name && hash) {
+				remoteBranches.set(name, hash);
+			}
+		}
+		for (const [name, hash] of Object.entries(delta.amendedRemoteBranches || {})) {
+			if (name && hash) {
+				remoteBranches.set(name, hash);
+			}
+		}
+		for (const name of Object.keys(delta.deletedRemoteBranches || {})) {
+			remoteBranches.delete(name);
+		}
+
+		for (const stash of delta.deletedStashes || []) {
+			if (stash?.hash) {
+				stashes.delete(stash.hash);
+			}
+		}
+		for (const stash of delta.addedStashes || []) {
+			if (stash?.hash) {
+				stashes.set(stash.hash, stash);
+			}
+		}
+
+		for (const [path, worktree] of Object.entries(delta.addedWorktrees || {})) {
+			if (path && worktree) {
+				worktrees.set(path, worktree);
+			}
+		}
+		for (const [path, worktree] of Object.entries(delta.amendedWorktrees || {})) {
+			if (path && worktree) {
+				worktrees.set(path, worktree);
+			}
+		}
+		for (const path of Object.keys(delta.deletedWorktrees || {})) {
+			worktrees.delete(path);
+		}
+
+		for (const hash of delta.addedShallows || []) {
+			const commit = commits.get(hash);
+			if (commit) {
+				commit.shallow = true;
+			}
+		}
+		for (const hash of delta.deletedShallows || []) {
+			const commit = commits.get(hash);
+			if (commit) {
+				commit.shallow = false;
+			}
+		}
+
+		for (const [hash, notes] of Object.entries(delta.updatedNotes || {})) {
+			const commit = commits.get(hash);
+			if (commit) {
+				commit.notes = notes;
+			}
+		}
+		const target = tooltipManager.getTargetData();
+		if (target?.type === "commit" && delta.updatedNotes?.[target.hash]) {
+			tooltipManager.show(target, zoomTransform);
+		}
+
 		updateGraph();
 	}
 
@@ -15,6 +15,11 @@ import {
     LABEL_PADDING,
     LINK_THICKNESS,
     NODE_RADIUS,
+    SHALLOW_MARKER_GAP,
+    SHALLOW_MARKER_LABEL,
+    STASH_NODE_SIZE,
+    WORKTREE_MARKER_GAP,
+    WORKTREE_MARKER_LINE_HEIGHT,
 } from "../constants.js";
 import { shortenHash } from "../../utils/format.js";
 
@@ -119,7 +124,7 @@ export class GraphRenderer {
 
             const prevAlpha = this.ctx.globalAlpha;
             this.ctx.globalAlpha = prevAlpha * warmup;
-            this.renderLink(source, target, link.kind === "branch");
+            this.renderLink(source, target, link.kind);
             this.ctx.globalAlpha = prevAlpha;
         }
     }
@@ -142,15 +147,20 @@ export class GraphRenderer {
      *
      * @param {import("../types.js").GraphNode} source Source node.
      * @param {import("../types.js").GraphNode} target Target node.
-     * @param {boolean} isBranch True when the arrow represents a branch link.
+     * @param {string} [kind] Link kind: "branch", "stash", or undefined for commit parents.
      */
-    renderLink(source, target, isBranch) {
+    renderLink(source, target, kind) {
         const dx = target.x - source.x;
         const dy = target.y - source.y;
         const distance = Math.sqrt(dx * dx + dy * dy);
         if (distance === 0) return;
 
-        const color = isBranch ? this.palette.branchLink : this.palette.link;
+        let color = this.palette.link;
+        if (kind === "branch") {
+            color = this.palette.branchLink;
+        } else if (kind === "stash") {
+            color = this.palette.stashLink;
+        }
         const targetRadius =
             target.type === "branch" ? BRANCH_NODE_RADIUS : NODE_RADIUS;
 
@@ -211,6 +221,11 @@ export class GraphRenderer {
                 this.renderCommitNode(node, highlightKey);
             }
         }
+        for (const node of nodes) {
+            if (node.type === "stash") {
+                this.renderStashNode(node, highlightKey);
+            }
+        }
         for (const node of nodes) {
             if (node.type === "branch") {
                 this.renderBranchNode(node, highlightKey);
@@ -218,6 +233,53 @@ export class GraphRenderer {
         }
     }
 
+    /**
+     * Draws a stash node as a rotated square labelled with its stash@{n} name.
+     *
+     * @param {import("../types.js").GraphNodeStash} node Stash node to paint.
+     * @param {string|null} highlightKey Current highlight identifier.
+     */
+    renderStashNode(node, highlightKey) {
+        const isHighlighted = highlightKey && node.hash === highlightKey;
+
+        const spawnProgress =
+            typeof node.spawnPhase === "number" ? node.spawnPhase : 1;
+        const nextSpawn = spawnProgress < 1 ? Math.min(1, spawnProgress + 0.12) : 1;
+        if (nextSpawn >= 1) {
+            delete node.spawnPhase;
+        } else {
+            node.spawnPhase = nextSpawn;
+        }
+
+        const size = isHighlighted ? STASH_NODE_SIZE + 3 : STASH_NODE_SIZE;
+
+        this.ctx.save();
+        this.ctx.globalAlpha *= Math.max(0.01, spawnProgress);
+        this.ctx.translate(node.x, node.y);
+        this.ctx.rotate(Math.PI / 4);
+        this.ctx.fillStyle = this.palette.stashNode;
+        this.ctx.fillRect(-size / 2, -size / 2, size, size);
+        this.ctx.restore();
+
+        const text = node.stash?.name ?? "";
+        if (!text) return;
+
+        this.ctx.save();
+        this.ctx.globalAlpha *= spawnProgress;
+        this.ctx.font = LABEL_FONT;
+        this.ctx.textBaseline = "middle";
+        this.ctx.textAlign = "right";
+
+        const labelX = node.x - size / 2 - LABEL_PADDING;
+        this.ctx.lineWidth = 3;
+        this.ctx.lineJoin = "round";
+        this.ctx.strokeStyle = this.palette.labelHalo;
+        this.ctx.strokeText(text, labelX, node.y);
+        this.ctx.fillStyle = this.palette.labelText;
+        this.ctx.fillText(text, labelX, node.y);
+        this.ctx.restore();
+    }
+
     /**
      * Draws a commit node including adaptive highlighting.
      *
@@ -252,11 +314,77 @@ export class GraphRenderer {
         } else {
             this.renderNormalCommit(node, drawRadius);
         }
+        if (node.commit?.shallow) {
+            this.renderShallowMarker(node, drawRadius);
+        }
+        if (node.worktrees?.length) {
+            this.renderWorktreeMarkers(node, drawRadius);
+        }
         this.ctx.globalAlpha = previousAlpha;
 
         this.renderCommitLabel(node, spawnAlpha);
     }
 
+    /**
+     * Marks a shallow boundary commit, whose parents were not fetched, with a dashed ring and a
+     * caption noting that history is truncated there.
+     *
+     * @param {import("../types.js").GraphNodeCommit} node Shallow commit node to annotate.
+     * @param {number} radius Current drawn radius of the node.
+     */
+    renderShallowMarker(node, radius) {
+        this.ctx.save();
+        this.ctx.strokeStyle = this.palette.link;
+        this.ctx.lineWidth = LINK_THICKNESS;
+        this.ctx.setLineDash([2, 2]);
+        this.ctx.beginPath();
+        this.ctx.arc(node.x, node.y, radius + SHALLOW_MARKER_GAP, 0, Math.PI * 2);
+        this.ctx.stroke();
+
+        this.ctx.font = LABEL_FONT;
+        this.ctx.textAlign = "center";
+        this.ctx.textBaseline = "top";
+        this.ctx.fillStyle = this.palette.link;
+        this.ctx.fillText(
+            SHALLOW_MARKER_LABEL,
+            node.x,
+            node.y + radius + SHALLOW_MARKER_GAP + LABEL_PADDING / 2,
+        );
+        this.ctx.restore();
+    }
+
+    /**
+     * Marks a commit checked out in one or more worktrees with a solid ring and a stacked HEAD
+     * caption per worktree above the node. The worktree GitVista was opened from is shown in bold.
+     *
+     * @param {import("../types.js").GraphNodeCommit} node Commit node to annotate.
+     * @param {number} radius Current drawn radius of the node.
+     */
+    renderWorktreeMarkers(node, radius) {
+        this.ctx.save();
+        this.ctx.strokeStyle = this.palette.worktreeHead;
+        this.ctx.lineWidth = LINK_THICKNESS;
+        this.ctx.beginPath();
+        this.ctx.arc(node.x, node.y, radius + WORKTREE_MARKER_GAP, 0, Math.PI * 2);
+        this.ctx.stroke();
+
+        this.ctx.textAlign = "center";
+        this.ctx.textBaseline = "bottom";
+        this.ctx.lineWidth = 3;
+        this.ctx.lineJoin = "round";
+        let labelY = node.y - radius - WORKTREE_MARKER_GAP - LABEL_PADDING / 2;
+        for (const worktree of node.worktrees) {
+            const text = worktree.main ? "HEAD" : `HEAD (${worktree.name})`;
+            this.ctx.font = worktree.current ? `bold ${LABEL_FONT}` : LABEL_FONT;
+            this.ctx.strokeStyle = this.palette.labelHalo;
+            this.ctx.strokeText(text, node.x, labelY);
+            this.ctx.fillStyle = this.palette.worktreeHead;
+            this.ctx.fillText(text, node.x, labelY);
+            labelY -= WORKTREE_MARKER_LINE_HEIGHT;
+        }
+        this.ctx.restore();
+    }
+
     /**
      * Renders a non-highlighted commit node.
      *
@@ -343,7 +471,8 @@ export class GraphRenderer {
     }
 
     /**
-     * Renders a branch node pill with text.
+     * Renders a branch node pill with text. Remote-tracking branches are drawn outlined rather
+     * than filled, so local and remote positions can be told apart at a glance.
      *
      * @param {import("../types.js").GraphNodeBranch} node Branch node to paint.
      * @param {string|null} highlightKey Current highlight identifier.
@@ -387,9 +516,13 @@ export class GraphRenderer {
             BRANCH_NODE_CORNER_RADIUS,
         );
 
-        this.ctx.fillStyle = isHighlighted
-            ? this.palette.nodeHighlight
-            : this.palette.branchNode;
+        if (isHighlighted) {
+            this.ctx.fillStyle = this.palette.nodeHighlight;
+        } else if (node.remote) {
+            this.ctx.fillStyle = this.palette.background;
+        } else {
+            this.ctx.fillStyle = this.palette.branchNode;
+        }
         this.ctx.fill();
         const baseLineWidth = isHighlighted ? 2 : 1.5;
         this.ctx.lineWidth = baseLineWidth / scale;
@@ -398,7 +531,10 @@ export class GraphRenderer {
             : this.palette.branchNodeBorder;
         this.ctx.stroke();
 
-        this.ctx.fillStyle = this.palette.branchLabelText;
+        this.ctx.fillStyle =
+            node.remote && !isHighlighted
+                ? this.palette.branchNode
+                : this.palette.branchLabelText;
         this.ctx.fillText(text, node.x, node.y);
         this.ctx.globalAlpha = previousAlpha;
         this.ctx.restore();
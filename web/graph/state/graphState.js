@@ -14,6 +14,9 @@ export function createGraphState() {
 	return {
 		commits: new Map(),
 		branches: new Map(),
+		remoteBranches: new Map(),
+		stashes: new Map(),
+		worktrees: new Map(),
 		nodes: [],
 		links: [],
 		zoomTransform: d3.zoomIdentity,
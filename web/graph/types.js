@@ -20,6 +20,27 @@
  * @property {GraphSignature} [author] Author metadata.
  * @property {GraphSignature} [committer] Committer metadata.
  * @property {string[]} [parents] Array of parent commit hashes.
+ * @property {Record<string, string>} [notes] Git notes text keyed by notes ref name.
+ * @property {boolean} [shallow] Whether this is a shallow clone boundary with unfetched parents.
+ * @property {GraphSubmodule[]} [submodules] Submodules pinned by the commit, fetched on demand.
+ * @property {GraphFileChange[]} [changes] Files changed relative to the first parent, fetched on demand.
+ */
+
+/**
+ * @typedef {Object} GraphFileChange
+ * @property {"added"|"modified"|"deleted"|"typechange"|"renamed"|"copied"} type How the path changed.
+ * @property {string} path Path after the change.
+ * @property {string} [oldPath] Source path of a rename or copy.
+ * @property {number} [similarity] Similarity percentage of a rename or copy.
+ */
+
+/**
+ * @typedef {Object} GraphSubmodule
+ * @property {string} name Submodule name in .gitmodules.
+ * @property {string} path Path of the gitlink in the superproject tree.
+ * @property {string} [url] Configured clone URL.
+ * @property {string} [commit] Submodule commit pinned by the gitlink.
+ * @property {boolean} [initialized] Whether the submodule has been cloned and can be opened.
  */
 
 /**
@@ -30,11 +51,25 @@
  * @property {number} vy Velocity along the Y axis.
  */
 
+/**
+ * @typedef {Object} GraphWorktree
+ * @property {string} name Directory name under worktrees/, or empty for the main worktree.
+ * @property {string} path Checkout path.
+ * @property {string} [head] Hash of the commit checked out, absent while the branch is unborn.
+ * @property {string} [branch] Full name of the checked-out branch, absent when detached.
+ * @property {boolean} [detached] Whether HEAD is detached.
+ * @property {boolean} [main] Whether this is the main worktree.
+ * @property {boolean} [current] Whether GitVista was opened from this worktree.
+ * @property {boolean} [locked] Whether the worktree is locked against pruning.
+ * @property {boolean} [prunable] Whether the worktree's checkout no longer exists.
+ */
+
 /**
  * @typedef {GraphNodeBase & {
  *   type: "commit",
  *   hash: string,
  *   commit?: GraphCommit,
+ *   worktrees?: GraphWorktree[],
  *   radius?: number
  * }} GraphNodeCommit
  */
@@ -43,12 +78,33 @@
  * @typedef {GraphNodeBase & {
  *   type: "branch",
  *   branch: string,
+ *   remote?: boolean,
  *   targetHash: string | null
  * }} GraphNodeBranch
  */
 
 /**
- * @typedef {GraphNodeCommit | GraphNodeBranch} GraphNode
+ * @typedef {Object} GraphStash
+ * @property {string} hash Stash commit SHA identifier.
+ * @property {string} name Stash entry name, such as "stash@{0}".
+ * @property {string} base Hash of the commit the stash was made on.
+ * @property {string} index Hash of the commit recording the staged changes.
+ * @property {string} [untracked] Hash of the commit recording untracked files, if any.
+ * @property {GraphSignature} [author] Author metadata.
+ * @property {string} [message] Stash message.
+ */
+
+/**
+ * @typedef {GraphNodeBase & {
+ *   type: "stash",
+ *   hash: string,
+ *   stash: GraphStash,
+ *   targetHash: string
+ * }} GraphNodeStash
+ */
+
+/**
+ * @typedef {GraphNodeCommit | GraphNodeBranch | GraphNodeStash} GraphNode
  */
 
 /**
@@ -62,6 +118,9 @@
  * @property {string} branchNodeBorder Branch node stroke color.
  * @property {string} branchLabelText Branch label text color.
  * @property {string} branchLink Branch link color.
+ * @property {string} stashNode Stash node fill color.
+ * @property {string} stashLink Stash link color.
+ * @property {string} worktreeHead Worktree HEAD marker color.
  * @property {string} nodeHighlight Node highlight fill color.
  * @property {string} nodeHighlightGlow Glow color for highlighted nodes.
  * @property {string} nodeHighlightCore Inner highlight color for commits.
@@ -72,6 +131,9 @@
  * @typedef {Object} GraphState
  * @property {Map<string, GraphCommit>} commits Map of commit hash to commit data.
  * @property {Map<string, string>} branches Map of branch name to target hash.
+ * @property {Map<string, string>} remoteBranches Map of remote-tracking branch name (e.g. "origin/main") to target hash.
+ * @property {Map<string, GraphStash>} stashes Map of stash commit hash to stash data.
+ * @property {Map<string, GraphWorktree>} worktrees Map of worktree checkout path to worktree data.
  * @property {GraphNode[]} nodes Collection of nodes rendered on the canvas.
  * @property {Array<{source: string | GraphNode, target: string | GraphNode, kind?: string}>} links Force simulation link definitions.
  * @property {import("d3").ZoomTransform} zoomTransform Current D3 zoom transform.
@@ -25,6 +25,9 @@ export function buildPalette(element) {
 		branchNodeBorder: read("--branch-node-border-color", "#59339d"),
 		branchLabelText: read("--branch-label-text-color", "#ffffff"),
 		branchLink: read("--branch-link-color", "#6f42c1"),
+		stashNode: read("--stash-node-color", "#bf8700"),
+		stashLink: read("--stash-link-color", "#d4a72c"),
+		worktreeHead: read("--worktree-head-color", "#1a7f37"),
 		nodeHighlight: read("--node-highlight-color", "#1f6feb"),
 		nodeHighlightGlow: read(
 			"--node-highlight-glow",
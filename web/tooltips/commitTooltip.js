@@ -4,6 +4,21 @@
  */
 
 import { Tooltip, createTooltipElement } from "./baseTooltip.js";
+import { apiPath, currentSubmodule } from "../utils/api.js";
+import { shortenHash } from "../utils/format.js";
+
+/** Most changed files listed before the rest are summarized in a count. */
+const MAX_LISTED_CHANGES = 20;
+
+/** One-letter status of each change type, as in git diff --name-status. */
+const CHANGE_STATUS = {
+    added: "A",
+    modified: "M",
+    deleted: "D",
+    typechange: "T",
+    renamed: "R",
+    copied: "C",
+};
 
 /**
  * Tooltip that displays commit details such as hash, author, and message.
@@ -34,8 +49,11 @@ export class CommitTooltip extends Tooltip {
         this.headerEl.append(this.hashEl, this.metaEl);
 
         this.messageEl = createTooltipElement("pre", "commit-tooltip-message");
+        this.changesEl = createTooltipElement("div", "commit-tooltip-changes");
+        this.notesEl = createTooltipElement("div", "commit-tooltip-notes");
+        this.submodulesEl = createTooltipElement("div", "commit-tooltip-submodules");
 
-        tooltip.append(this.headerEl, this.messageEl);
+        tooltip.append(this.headerEl, this.messageEl, this.changesEl, this.notesEl, this.submodulesEl);
         // document.body.appendChild(...) -> inserts the tooltip into the live DOM tree.
         document.body.appendChild(tooltip);
         return tooltip;
@@ -76,10 +94,165 @@ export class CommitTooltip extends Tooltip {
             metaParts.push(date.toLocaleString());
         }
         this.metaEl.textContent = metaParts.join(" • ");
+        this.buildChanges(commit.changes);
+        if (!commit.changes) {
+            this.loadChanges(node);
+        }
+        this.buildNotes(commit.notes);
+        this.buildSubmodules(commit.submodules);
+        if (!commit.submodules) {
+            this.loadSubmodules(node);
+        }
 
+        if (commit.partial) {
+            this.messageEl.textContent = "Loading…";
+            this.loadDetails(node);
+            return;
+        }
         this.messageEl.textContent = commit.message || "(no message)";
     }
 
+    /**
+     * Lists the files a commit changed relative to its first parent, with a status letter each.
+     * Long lists are cut short after MAX_LISTED_CHANGES entries.
+     *
+     * @param {import("../graph/types.js").GraphFileChange[] | undefined} changes Changed files, sorted by path.
+     */
+    buildChanges(changes) {
+        this.changesEl.replaceChildren();
+        this.changesEl.hidden = !changes?.length;
+
+        for (const change of (changes ?? []).slice(0, MAX_LISTED_CHANGES)) {
+            const entryEl = createTooltipElement("div", "commit-tooltip-change");
+            const path = change.oldPath ? `${change.oldPath} → ${change.path}` : change.path;
+            entryEl.textContent = `${CHANGE_STATUS[change.type] ?? "?"} ${path}`;
+            this.changesEl.append(entryEl);
+        }
+        if (changes?.length > MAX_LISTED_CHANGES) {
+            const moreEl = createTooltipElement("div", "commit-tooltip-change");
+            moreEl.textContent = `… and ${changes.length - MAX_LISTED_CHANGES} more`;
+            this.changesEl.append(moreEl);
+        }
+    }
+
+    /**
+     * Fetches the files changed by a commit, then re-renders if the tooltip still targets the
+     * same node.
+     *
+     * @param {import("../graph/types.js").GraphNodeCommit} node Commit node data.
+     */
+    async loadChanges(node) {
+        const commit = node.commit;
+        if (commit.changesRequest) {
+            return;
+        }
+
+        commit.changesRequest = fetch(apiPath(`/api/commits/${commit.hash}/changes`))
+            .then((response) => (response.ok ? response.json() : null))
+            .catch(() => null);
+        const changes = await commit.changesRequest;
+        delete commit.changesRequest;
+
+        commit.changes = changes ?? [];
+        if (this.targetData === node) {
+            this.buildChanges(commit.changes);
+        }
+    }
+
+    /**
+     * Lists the git notes attached to a commit, one section per notes ref.
+     *
+     * @param {Record<string, string> | undefined} notes Note text keyed by notes ref name.
+     */
+    buildNotes(notes) {
+        const entries = Object.entries(notes ?? {});
+        this.notesEl.replaceChildren();
+        this.notesEl.hidden = entries.length === 0;
+
+        for (const [ref, text] of entries) {
+            const refEl = createTooltipElement("div", "commit-tooltip-notes-ref");
+            refEl.textContent = `Notes (${ref}):`;
+            const textEl = createTooltipElement("pre", "commit-tooltip-message");
+            textEl.textContent = text.trimEnd();
+            this.notesEl.append(refEl, textEl);
+        }
+    }
+
+    /**
+     * Lists the submodule commit pinned by each gitlink in the commit's tree. Initialized
+     * submodules link to a view of their own graph.
+     *
+     * @param {import("../graph/types.js").GraphSubmodule[] | undefined} submodules Submodules declared at the commit.
+     */
+    buildSubmodules(submodules) {
+        this.submodulesEl.replaceChildren();
+        this.submodulesEl.hidden = !submodules?.length;
+
+        for (const submodule of submodules ?? []) {
+            const pinned = submodule.commit ? shortenHash(submodule.commit) : "(no gitlink)";
+            // Nested submodules are only reachable from the superproject's own view.
+            const linked = submodule.initialized && !currentSubmodule();
+            const entryEl = createTooltipElement(linked ? "a" : "div", "commit-tooltip-submodule");
+            entryEl.textContent = `${submodule.path} @ ${pinned}`;
+            if (linked) {
+                entryEl.href = `?submodule=${encodeURIComponent(submodule.name)}`;
+                entryEl.target = "_blank";
+            }
+            this.submodulesEl.append(entryEl);
+        }
+    }
+
+    /**
+     * Fetches the submodules pinned by a commit, then re-renders if the tooltip still targets
+     * the same node.
+     *
+     * @param {import("../graph/types.js").GraphNodeCommit} node Commit node data.
+     */
+    async loadSubmodules(node) {
+        const commit = node.commit;
+        if (commit.submodulesRequest) {
+            return;
+        }
+
+        commit.submodulesRequest = fetch(apiPath(`/api/commits/${commit.hash}/submodules`))
+            .then((response) => (response.ok ? response.json() : null))
+            .catch(() => null);
+        const submodules = await commit.submodulesRequest;
+        delete commit.submodulesRequest;
+
+        commit.submodules = submodules ?? [];
+        if (this.targetData === node) {
+            this.buildSubmodules(commit.submodules);
+        }
+    }
+
+    /**
+     * Fetches author and message for commits the server sent without them (loaded from the
+     * commit-graph), then re-renders if the tooltip still targets the same node.
+     *
+     * @param {import("../graph/types.js").GraphNodeCommit} node Commit node data.
+     */
+    async loadDetails(node) {
+        const commit = node.commit;
+        if (commit.detailsRequest) {
+            return;
+        }
+
+        commit.detailsRequest = fetch(apiPath(`/api/commits/${commit.hash}`))
+            .then((response) => (response.ok ? response.json() : null))
+            .catch(() => null);
+        const details = await commit.detailsRequest;
+        delete commit.detailsRequest;
+        if (!details) {
+            return;
+        }
+
+        Object.assign(commit, details, { partial: false });
+        if (this.targetData === node) {
+            this.buildContent(node);
+        }
+    }
+
     /**
      * @param {import("../graph/types.js").GraphNodeCommit} node Commit node used for anchoring.
      * @returns {{x: number, y: number}} Logical coordinates for tooltip placement.
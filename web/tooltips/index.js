@@ -5,6 +5,7 @@
 
 import { CommitTooltip } from "./commitTooltip.js";
 import { BranchTooltip } from "./branchTooltip.js";
+import { StashTooltip } from "./stashTooltip.js";
 
 /**
  * Central coordinator that dispatches tooltip rendering based on node type.
@@ -18,6 +19,7 @@ export class TooltipManager {
         this.tooltips = {
             commit: new CommitTooltip(canvas),
             branch: new BranchTooltip(canvas),
+            stash: new StashTooltip(canvas),
         };
         this.activeTooltip = null;
     }
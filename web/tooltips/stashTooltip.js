@@ -0,0 +1,101 @@
+/**
+ * @fileoverview Stash tooltip implementation for the Git graph UI.
+ * Renders the stash entry name, the commit it was made on, and its message.
+ */
+
+import { Tooltip, createTooltipElement } from "./baseTooltip.js";
+import { shortenHash } from "../utils/format.js";
+
+/**
+ * Tooltip that presents stash metadata. Shares the commit tooltip's layout.
+ */
+export class StashTooltip extends Tooltip {
+    /**
+     * @param {HTMLCanvasElement} canvas Canvas that anchors tooltip positioning.
+     */
+    constructor(canvas) {
+        super(canvas);
+    }
+
+    /**
+     * Builds the DOM structure for stash tooltips.
+     *
+     * @returns {HTMLDivElement} Tooltip root element appended to the document body.
+     */
+    createElement() {
+        const tooltip = /** @type {HTMLDivElement} */ (
+            createTooltipElement("div", this.getClassName())
+        );
+        tooltip.hidden = true;
+
+        this.headerEl = createTooltipElement("div", "commit-tooltip-header");
+        this.nameEl = createTooltipElement("code", "commit-tooltip-hash");
+        this.metaEl = createTooltipElement("div", "commit-tooltip-meta");
+        this.headerEl.append(this.nameEl, this.metaEl);
+
+        this.messageEl = createTooltipElement("pre", "commit-tooltip-message");
+
+        tooltip.append(this.headerEl, this.messageEl);
+        // document.body.appendChild(...) keeps the tooltip available for display updates.
+        document.body.appendChild(tooltip);
+        return tooltip;
+    }
+
+    /**
+     * @returns {string} CSS classes scoped to stash tooltips.
+     */
+    getClassName() {
+        return "commit-tooltip stash-tooltip";
+    }
+
+    /**
+     * @param {import("../graph/types.js").GraphNodeStash} node Potential stash node.
+     * @returns {boolean} True when the node represents a stash with metadata.
+     */
+    validate(node) {
+        return node && node.type === "stash" && node.stash;
+    }
+
+    /**
+     * Populates tooltip with the stash name, base commit, and message.
+     *
+     * @param {import("../graph/types.js").GraphNodeStash} node Stash node data.
+     */
+    buildContent(node) {
+        const stash = node.stash;
+
+        this.nameEl.textContent = stash.name;
+
+        const metaParts = [`on ${shortenHash(stash.base)}`];
+        if (stash.untracked) {
+            metaParts.push("includes untracked files");
+        }
+        if (stash.author?.when) {
+            metaParts.push(new Date(stash.author.when).toLocaleString());
+        }
+        this.metaEl.textContent = metaParts.join(" • ");
+        this.messageEl.textContent = stash.message || "(no message)";
+    }
+
+    /**
+     * @param {import("../graph/types.js").GraphNodeStash} node Stash node used for anchoring.
+     * @returns {{x: number, y: number}} Logical coordinates for tooltip placement.
+     */
+    getTargetPosition(node) {
+        return { x: node.x, y: node.y };
+    }
+
+    /**
+     * @returns {{x: number, y: number}} Tooltip offset relative to stash node.
+     */
+    getOffset() {
+        return { x: 20, y: -10 };
+    }
+
+    /**
+     * @returns {string|null} Stash commit hash used to highlight the corresponding node.
+     */
+    getHighlightKey() {
+        return this.targetData?.hash || null;
+    }
+}
@@ -0,0 +1,29 @@
+/**
+ * @fileoverview Helpers for addressing the GitVista API.
+ * A page opened with a "submodule" query parameter views that submodule rather than the
+ * superproject, so every request it makes carries the same parameter.
+ */
+
+/**
+ * Returns the submodule the page is viewing, if any.
+ *
+ * @returns {string} Submodule name, or an empty string for the superproject.
+ */
+export function currentSubmodule() {
+    return new URLSearchParams(window.location.search).get("submodule") ?? "";
+}
+
+/**
+ * Scopes an API path to the repository the page is viewing.
+ *
+ * @param {string} path API path, e.g. "/api/commits/<hash>".
+ * @returns {string} Path with the submodule query parameter appended when needed.
+ */
+export function apiPath(path) {
+    const submodule = currentSubmodule();
+    if (!submodule) {
+        return path;
+    }
+    const separator = path.includes("?") ? "&" : "?";
+    return `${path}${separator}submodule=${encodeURIComponent(submodule)}`;
+}